@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/atvaark/dragons-dogma-server/modules/admin"
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+	"github.com/urfave/cli"
+)
+
+const (
+	adminURLFlagName     = "url"
+	adminTokenFlagName   = "token"
+	adminMessageFlagName = "message"
+	adminHostFlagName    = "host"
+	adminPortFlagName    = "port"
+	adminKeepFlagName    = "keepSessions"
+	adminGameTokenName   = "gameToken"
+
+	adminURLFlagDefault = "http://localhost:12503"
+)
+
+var adminFlags = []cli.Flag{
+	cli.StringFlag{Name: adminURLFlagName, Value: adminURLFlagDefault, Usage: "address of the admin API of the web command"},
+	cli.StringFlag{Name: adminTokenFlagName, EnvVar: "DDDA_ADMIN_TOKEN"},
+}
+
+var AdminCommand = cli.Command{
+	Name:        "admin",
+	Description: "Controls the connections of a running server through its admin API",
+	Subcommands: []cli.Command{
+		{
+			Name:        "connections",
+			Description: "Lists the connected clients",
+			Flags:       adminFlags,
+			Action:      runAdminConnections,
+		},
+		{
+			Name:        "kick",
+			Description: "Disconnects a client, takes the connection id as argument",
+			Flags:       append([]cli.Flag{cli.StringFlag{Name: adminMessageFlagName, Usage: "shown to the client"}}, adminFlags...),
+			Action:      runAdminKick,
+		},
+		{
+			Name:        "broadcast",
+			Description: "Tells every client to disconnect, or to reconnect to another server if a host is given",
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: adminMessageFlagName, Usage: "shown to the clients when they are disconnected"},
+				cli.StringFlag{Name: adminHostFlagName, Usage: "server the clients reconnect to"},
+				cli.IntFlag{Name: adminPortFlagName, Value: gamePortFlagDefault, Usage: "port the clients reconnect to"},
+			}, adminFlags...),
+			Action: runAdminBroadcast,
+		},
+		{
+			Name:        "maintenance",
+			Description: "Shows the maintenance mode, or turns it on or off with the argument on or off",
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: adminMessageFlagName, Value: maintenanceMessageDefault, Usage: "shown to refused and disconnected clients"},
+				cli.BoolFlag{Name: adminKeepFlagName, Usage: "lets existing sessions finish"},
+			}, adminFlags...),
+			Action: runAdminMaintenance,
+		},
+		{
+			Name:        "token",
+			Description: "Registers the base64 game token of a user for the database token verifier, takes the user as argument",
+			Flags:       append([]cli.Flag{cli.StringFlag{Name: adminGameTokenName, Usage: "base64 encoded token"}}, adminFlags...),
+			Action:      runAdminToken,
+		},
+	},
+}
+
+func newAdminClient(ctx *cli.Context) *admin.Client {
+	token := ctx.String(adminTokenFlagName)
+	if len(token) == 0 {
+		panic(errors.New("missing admin token"))
+	}
+
+	return admin.NewClient(ctx.String(adminURLFlagName), token)
+}
+
+func runAdminConnections(ctx *cli.Context) {
+	connections, err := newAdminClient(ctx).Connections()
+	if err != nil {
+		panic(err)
+	}
+
+	connectionsJson, err := json.MarshalIndent(connections, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(connectionsJson))
+}
+
+func runAdminKick(ctx *cli.Context) {
+	connID, err := strconv.ParseInt(ctx.Args().First(), 10, 64)
+	if err != nil {
+		panic(fmt.Errorf("invalid connection id %q", ctx.Args().First()))
+	}
+
+	err = newAdminClient(ctx).Kick(connID, ctx.String(adminMessageFlagName))
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("kicked connection %d\n", connID)
+}
+
+func runAdminBroadcast(ctx *cli.Context) {
+	client := newAdminClient(ctx)
+
+	var sent int
+	var err error
+	if host := ctx.String(adminHostFlagName); len(host) > 0 {
+		sent, err = client.BroadcastReconnection(host, uint16(ctx.Int(adminPortFlagName)))
+	} else {
+		sent, err = client.BroadcastDisconnection(ctx.String(adminMessageFlagName))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("sent to %d clients\n", sent)
+}
+
+func runAdminMaintenance(ctx *cli.Context) {
+	client := newAdminClient(ctx)
+
+	var maintenance network.Maintenance
+	switch ctx.Args().First() {
+	case "":
+		var err error
+		maintenance, err = client.Maintenance()
+		if err != nil {
+			panic(err)
+		}
+	case "on":
+		maintenance = network.Maintenance{
+			Enabled:      true,
+			Message:      ctx.String(adminMessageFlagName),
+			KeepSessions: ctx.Bool(adminKeepFlagName),
+		}
+
+		disconnected, err := client.SetMaintenance(maintenance)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("disconnected %d clients\n", disconnected)
+	case "off":
+		_, err := client.SetMaintenance(maintenance)
+		if err != nil {
+			panic(err)
+		}
+	default:
+		panic(fmt.Errorf("unknown argument %s, use on or off", ctx.Args().First()))
+	}
+
+	maintenanceJson, err := json.MarshalIndent(maintenance, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(maintenanceJson))
+}
+
+func runAdminToken(ctx *cli.Context) {
+	user := ctx.Args().First()
+	if len(user) == 0 {
+		panic(errors.New("missing user"))
+	}
+
+	token, err := base64.StdEncoding.DecodeString(ctx.String(adminGameTokenName))
+	if err != nil {
+		panic(fmt.Errorf("invalid base64 token: %v", err))
+	}
+	if len(token) == 0 {
+		panic(errors.New("missing game token"))
+	}
+
+	err = newAdminClient(ctx).PutGameToken(user, token)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("registered the game token of %s\n", user)
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+	"github.com/urfave/cli"
+)
+
+const (
+	dissectFileFlagName      = "file"
+	dissectFormatFlagName    = "format"
+	dissectDirectionFlagName = "direction"
+	dissectOutputFlagName    = "output"
+
+	dissectFileFlagDefault      = "-"
+	dissectFormatFlagDefault    = "raw"
+	dissectDirectionFlagDefault = "client"
+	dissectOutputFlagDefault    = "json"
+)
+
+var DissectCommand = cli.Command{
+	Name:        "dissect",
+	Description: "Splits a raw protocol stream or a capture file into packets and prints them",
+	Flags: withLogFlags(
+		cli.StringFlag{Name: dissectFileFlagName, Value: dissectFileFlagDefault, Usage: "input file, - reads from stdin"},
+		cli.StringFlag{Name: dissectFormatFlagName, Value: dissectFormatFlagDefault, Usage: "raw or capture"},
+		cli.StringFlag{Name: dissectDirectionFlagName, Value: dissectDirectionFlagDefault, Usage: "sender of a raw stream, client or server"},
+		cli.StringFlag{Name: dissectOutputFlagName, Value: dissectOutputFlagDefault, Usage: "json or hex"},
+	),
+	Action: runDissect,
+}
+
+type dissectConfig struct {
+	file      string
+	format    string
+	direction network.CaptureDirection
+	output    string
+	log       *logging.Logger
+}
+
+func (cfg *dissectConfig) parse(ctx *cli.Context) error {
+	cfg.file = ctx.String(dissectFileFlagName)
+	cfg.format = ctx.String(dissectFormatFlagName)
+	cfg.output = ctx.String(dissectOutputFlagName)
+
+	var err error
+	cfg.log, err = parseLogger(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch ctx.String(dissectDirectionFlagName) {
+	case "client":
+		cfg.direction = network.ClientToServer
+	case "server":
+		cfg.direction = network.ServerToClient
+	default:
+		return fmt.Errorf("unknown direction %s", ctx.String(dissectDirectionFlagName))
+	}
+
+	switch cfg.format {
+	case "raw", "capture":
+	default:
+		return fmt.Errorf("unknown format %s", cfg.format)
+	}
+
+	switch cfg.output {
+	case "json", "hex":
+	default:
+		return fmt.Errorf("unknown output %s", cfg.output)
+	}
+
+	return nil
+}
+
+type dissectedPacket struct {
+	Offset     int64                 `json:"offset"`
+	Direction  string                `json:"direction"`
+	SequenceID uint16                `json:"sequenceId"`
+	NameID     string                `json:"nameId"`
+	TypeID     string                `json:"typeId"`
+	ErrorID    string                `json:"errorId"`
+	Type       string                `json:"type"`
+	Length     uint16                `json:"length"`
+	Fields     []network.PacketField `json:"fields,omitempty"`
+	Payload    string                `json:"payload,omitempty"`
+	Warnings   []string              `json:"warnings,omitempty"`
+}
+
+func runDissect(ctx *cli.Context) {
+	var cfg dissectConfig
+	err := cfg.parse(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	input := io.Reader(os.Stdin)
+	if cfg.file != "-" {
+		file, err := os.Open(cfg.file)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	next, err := newDissectSource(&cfg, input)
+	if err != nil {
+		panic(err)
+	}
+
+	// Sequence ids are only continuous within a session, so captures of several sessions get a validator per connection.
+	validators := make(map[int64]*network.SequenceValidator)
+	for {
+		frame, direction, connID, err := next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			cfg.log.Errorf("failed to read packet: %v", err)
+			os.Exit(1)
+		}
+
+		packet := dissectFrame(frame, direction)
+		validator, ok := validators[connID]
+		if !ok {
+			validator = network.NewSequenceValidator()
+			validators[connID] = validator
+		}
+		if err := validator.Validate(direction, frame.Header); err != nil {
+			packet.Warnings = append(packet.Warnings, err.Error())
+		}
+
+		if cfg.output == "json" {
+			printDissectedJSON(packet)
+		} else {
+			printDissectedHex(packet, frame.Payload)
+		}
+	}
+}
+
+// newDissectSource returns a function that reads the next frame with its direction and the connection it was captured on.
+// Raw streams are a single connection with the id 0.
+func newDissectSource(cfg *dissectConfig, input io.Reader) (func() (*network.Frame, network.CaptureDirection, int64, error), error) {
+	if cfg.format == "raw" {
+		reader := network.NewFrameReader(input)
+		return func() (*network.Frame, network.CaptureDirection, int64, error) {
+			frame, err := reader.ReadFrame()
+			return frame, cfg.direction, 0, err
+		}, nil
+	}
+
+	reader, err := network.NewCaptureReader(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var index int64
+	return func() (*network.Frame, network.CaptureDirection, int64, error) {
+		record, err := reader.ReadRecord()
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		index++
+
+		frame := &network.Frame{Offset: index, Header: record.Header, Payload: record.Payload}
+		frame.Packet, frame.Err = network.DecodePacket(record.Header, record.Payload)
+		return frame, record.Direction, record.ConnID, nil
+	}, nil
+}
+
+func dissectFrame(frame *network.Frame, direction network.CaptureDirection) *dissectedPacket {
+	packetType := frame.Header.PacketType
+	packet := &dissectedPacket{
+		Offset:     frame.Offset,
+		Direction:  direction.String(),
+		SequenceID: frame.Header.SequenceID,
+		NameID:     fmt.Sprintf("0x%04x", uint16(packetType.NameID)),
+		TypeID:     fmt.Sprintf("0x%02x", uint8(packetType.TypeID)),
+		ErrorID:    fmt.Sprintf("0x%02x", uint8(packetType.ErrorID)),
+		Type:       packetType.String(),
+		Length:     frame.Header.Length,
+	}
+
+	if frame.Err != nil {
+		packet.Payload = hex.EncodeToString(frame.Payload)
+		packet.Warnings = append(packet.Warnings, frame.Err.Error())
+		return packet
+	}
+
+	if _, ok := frame.Packet.(*network.UnknownPacket); ok {
+		packet.Payload = hex.EncodeToString(frame.Payload)
+		packet.Warnings = append(packet.Warnings, "unknown packet")
+		return packet
+	}
+
+	packet.Fields = network.PacketFields(frame.Packet)
+	return packet
+}
+
+func printDissectedJSON(packet *dissectedPacket) {
+	packetJson, err := json.Marshal(packet)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(packetJson))
+}
+
+func printDissectedHex(packet *dissectedPacket, payload []byte) {
+	fmt.Printf("%d %s #%d %s (name %s type %s error %s, %d bytes)\n",
+		packet.Offset, packet.Direction, packet.SequenceID, packet.Type, packet.NameID, packet.TypeID, packet.ErrorID, packet.Length)
+
+	for _, field := range packet.Fields {
+		fmt.Printf("    %s = %s\n", field.Name, field.Value)
+	}
+
+	for _, warning := range packet.Warnings {
+		fmt.Printf("    warning: %s\n", warning)
+	}
+
+	if len(payload) > 0 {
+		fmt.Print(indent(hex.Dump(payload), "    "))
+	}
+}
+
+func indent(s string, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if len(line) > 0 {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+	"github.com/urfave/cli"
+)
+
+const (
+	logLevelFlagName  = "logLevel"
+	logFormatFlagName = "logFormat"
+
+	logLevelFlagDefault  = "info"
+	logFormatFlagDefault = "text"
+)
+
+// withLogFlags appends the flags that every command reads with parseLogger.
+func withLogFlags(flags ...cli.Flag) []cli.Flag {
+	return append(flags,
+		cli.StringFlag{Name: logLevelFlagName, Value: logLevelFlagDefault, Usage: "debug, info, warn or error"},
+		cli.StringFlag{Name: logFormatFlagName, Value: logFormatFlagDefault, Usage: "text or json"},
+	)
+}
+
+func parseLogger(ctx *cli.Context) (*logging.Logger, error) {
+	level, err := logging.ParseLevel(ctx.String(logLevelFlagName))
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := logging.ParseFormat(ctx.String(logFormatFlagName))
+	if err != nil {
+		return nil, err
+	}
+
+	return logging.New(os.Stderr, level, format), nil
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+	"github.com/urfave/cli"
+)
+
+const (
+	proxyPortFlagName                = "port"
+	proxyCertFileFlagName            = "certFile"
+	proxyKeyFileFlagName             = "keyFile"
+	proxyUpstreamHostFlagName        = "upstreamHost"
+	proxyUpstreamPortFlagName        = "upstreamPort"
+	proxyCaptureFileFlagName         = "captureFile"
+	proxyRewriteUserFlagName         = "rewriteUser"
+	proxyRewriteReconnectionFlagName = "rewriteReconnectionHost"
+
+	proxyPortFlagDefault         = 12501
+	proxyCertFileFlagDefault     = "server.crt"
+	proxyKeyFileFlagDefault      = "server.key"
+	proxyUpstreamHostFlagDefault = "dune.dragonsdogma.com"
+	proxyUpstreamPortFlagDefault = 12501
+)
+
+var ProxyCommand = cli.Command{
+	Name:        "proxy",
+	Description: "Forwards game clients to an upstream server and logs every packet",
+	Flags: withLogFlags(
+		cli.IntFlag{Name: proxyPortFlagName, Value: proxyPortFlagDefault},
+		cli.StringFlag{Name: proxyCertFileFlagName, Value: proxyCertFileFlagDefault},
+		cli.StringFlag{Name: proxyKeyFileFlagName, Value: proxyKeyFileFlagDefault},
+		cli.StringFlag{Name: proxyUpstreamHostFlagName, Value: proxyUpstreamHostFlagDefault},
+		cli.IntFlag{Name: proxyUpstreamPortFlagName, Value: proxyUpstreamPortFlagDefault},
+		cli.StringFlag{Name: proxyCaptureFileFlagName, Usage: "records all proxied sessions to this capture file"},
+		cli.StringFlag{Name: proxyRewriteUserFlagName, Usage: "replaces the user that clients log in with"},
+		cli.StringFlag{Name: proxyRewriteReconnectionFlagName, Usage: "redirects reconnection notifications to this host and the proxy port"},
+	),
+	Action: runProxy,
+}
+
+func parseProxyConfig(ctx *cli.Context) (network.ProxyConfig, error) {
+	cfg := network.ProxyConfig{
+		Port:         ctx.Int(proxyPortFlagName),
+		CertFile:     ctx.String(proxyCertFileFlagName),
+		KeyFile:      ctx.String(proxyKeyFileFlagName),
+		UpstreamHost: ctx.String(proxyUpstreamHostFlagName),
+		UpstreamPort: ctx.Int(proxyUpstreamPortFlagName),
+		CaptureFile:  ctx.String(proxyCaptureFileFlagName),
+	}
+
+	var err error
+	cfg.Logger, err = parseLogger(ctx)
+	if err != nil {
+		return cfg, err
+	}
+
+	if user := ctx.String(proxyRewriteUserFlagName); len(user) > 0 {
+		cfg.Rewriters = append(cfg.Rewriters, network.RewriteUser(user))
+	}
+
+	if host := ctx.String(proxyRewriteReconnectionFlagName); len(host) > 0 {
+		cfg.Rewriters = append(cfg.Rewriters, network.RewriteReconnection(host, uint16(cfg.Port)))
+	}
+
+	return cfg, nil
+}
+
+func runProxy(ctx *cli.Context) {
+	cfg, err := parseProxyConfig(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	proxy, err := network.NewProxy(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		err := proxy.ListenAndServe()
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	log := cfg.Logger
+	log.Infof("Started")
+
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt)
+	<-signalChannel
+
+	log.Infof("Stopping")
+	err = proxy.Close()
+	if err != nil {
+		log.Errorf("failed to close proxy: %v", err)
+	}
+	log.Infof("Stopped")
+}
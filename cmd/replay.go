@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"github.com/atvaark/dragons-dogma-server/modules/db"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+	"github.com/urfave/cli"
+)
+
+const (
+	replayFileFlagName         = "file"
+	replayHostFlagName         = "host"
+	replayPortFlagName         = "port"
+	replayConnectionFlagName   = "connection"
+	replayInProcessFlagName    = "inProcess"
+	replayDatabaseFileFlagName = "databaseFile"
+
+	replayHostFlagDefault = "localhost"
+	replayPortFlagDefault = 12501
+)
+
+var ReplayCommand = cli.Command{
+	Name:        "replay",
+	Description: "Replays the client side of a captured session and compares the server responses",
+	Flags: withLogFlags(
+		cli.StringFlag{Name: replayFileFlagName, Usage: "capture file to replay"},
+		cli.StringFlag{Name: replayHostFlagName, Value: replayHostFlagDefault},
+		cli.IntFlag{Name: replayPortFlagName, Value: replayPortFlagDefault},
+		cli.Int64Flag{Name: replayConnectionFlagName, Usage: "connection id to replay, defaults to the first one in the capture"},
+		cli.BoolFlag{Name: replayInProcessFlagName, Usage: "replays against a server started in this process"},
+		cli.StringFlag{Name: replayDatabaseFileFlagName, Usage: "database of the in-process server, defaults to a temporary one"},
+	),
+	Action: runReplay,
+}
+
+type replayConfig struct {
+	file         string
+	host         string
+	port         int
+	connection   int64
+	inProcess    bool
+	databaseFile string
+	log          *logging.Logger
+}
+
+func (cfg *replayConfig) parse(ctx *cli.Context) error {
+	cfg.file = ctx.String(replayFileFlagName)
+	cfg.host = ctx.String(replayHostFlagName)
+	cfg.port = ctx.Int(replayPortFlagName)
+	cfg.connection = ctx.Int64(replayConnectionFlagName)
+	cfg.inProcess = ctx.Bool(replayInProcessFlagName)
+	cfg.databaseFile = ctx.String(replayDatabaseFileFlagName)
+
+	if len(cfg.file) == 0 {
+		return errors.New("missing capture file")
+	}
+
+	var err error
+	cfg.log, err = parseLogger(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func runReplay(ctx *cli.Context) {
+	var cfg replayConfig
+	err := cfg.parse(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	differences, err := replay(&cfg)
+	if err != nil {
+		fmt.Printf("replay failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d responses differ\n", differences)
+	if differences > 0 {
+		os.Exit(1)
+	}
+}
+
+func replay(cfg *replayConfig) (int, error) {
+	records, err := readReplayRecords(cfg.file, cfg.connection)
+	if err != nil {
+		return 0, err
+	}
+
+	var conn *network.ClientConn
+	if cfg.inProcess {
+		var closeServer func()
+		conn, closeServer, err = startReplayServer(cfg.databaseFile, cfg.log)
+		if err != nil {
+			return 0, err
+		}
+		defer closeServer()
+	} else {
+		tlsConn, err := tls.Dial("tcp", fmt.Sprintf("%s:%d", cfg.host, cfg.port), &tls.Config{})
+		if err != nil {
+			return 0, err
+		}
+		defer tlsConn.Close()
+
+		conn = network.NewClientConn(tlsConn, 0, false)
+	}
+	conn.SetLogger(cfg.log)
+	conn.EnableKeepAlive(0, 0)
+
+	differences := 0
+	err = network.Replay(conn, records, func(result *network.ReplayResult) {
+		timestamp := result.Record.Time.Format("15:04:05.000")
+		if len(result.Diffs) == 0 {
+			fmt.Printf("%s %v: ok\n", timestamp, &result.Record.Header)
+			return
+		}
+
+		differences++
+		fmt.Printf("%s %v: %d differences\n", timestamp, &result.Record.Header, len(result.Diffs))
+		for _, diff := range result.Diffs {
+			fmt.Printf("    %v\n", diff)
+		}
+	})
+
+	return differences, err
+}
+
+func readReplayRecords(path string, connID int64) ([]*network.CaptureRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := network.NewCaptureReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*network.CaptureRecord
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if connID == 0 {
+			connID = record.ConnID
+		}
+
+		if record.ConnID == connID {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records of connection %d", connID)
+	}
+
+	return records, nil
+}
+
+func startReplayServer(databaseFile string, log *logging.Logger) (*network.ClientConn, func(), error) {
+	removeDatabase := func() {}
+	if len(databaseFile) == 0 {
+		tempFile, err := ioutil.TempFile("", "replay")
+		if err != nil {
+			return nil, nil, err
+		}
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+
+		databaseFile = tempFile.Name()
+		removeDatabase = func() { os.Remove(databaseFile) }
+	}
+
+	database, err := db.NewDatabase(databaseFile)
+	if err != nil {
+		removeDatabase()
+		return nil, nil, err
+	}
+
+	server := network.NewInProcessServer(network.ServerConfig{Logger: log}, database)
+	serverSide, clientSide := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ServeConn(serverSide)
+	}()
+
+	return network.NewClientConn(clientSide, 0, false), func() {
+		clientSide.Close()
+		err := <-done
+		if err != nil {
+			log.Errorf("in-process server failed: %v", err)
+		}
+		database.Close()
+		removeDatabase()
+	}, nil
+}
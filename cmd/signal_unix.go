@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+var maintenanceSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"os"
+)
+
+// Windows has no user signals, maintenance can only be toggled through the admin API there.
+var maintenanceSignals []os.Signal
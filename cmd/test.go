@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
 	"github.com/atvaark/dragons-dogma-server/modules/network"
 	"github.com/urfave/cli"
 )
@@ -16,36 +19,52 @@ const (
 	testUserFlagName            = "user"
 	testUserTokenFlagName       = "token"
 	testUserTokenFormatFlagName = "tokenFormat"
+	testCaptureFileFlagName     = "captureFile"
+	testTimeoutFlagName         = "timeout"
 
 	testHostFlagDefault            = "dune.dragonsdogma.com"
 	testPortFlagDefault            = 12501
 	testUserTokenFormatFlagDefault = "base64"
+	testTimeoutFlagDefault         = 30 * time.Second
 )
 
 var TestCommand = cli.Command{
 	Name:        "test",
 	Description: "Tests the server connection by logging in a user",
-	Flags: []cli.Flag{
+	Flags: withLogFlags(
 		cli.StringFlag{Name: testHostFlagName, Value: testHostFlagDefault},
 		cli.IntFlag{Name: testPortFlagName, Value: testPortFlagDefault},
 		cli.StringFlag{Name: testUserFlagName},
 		cli.StringFlag{Name: testUserTokenFlagName},
 		cli.StringFlag{Name: testUserTokenFormatFlagName, Value: testUserTokenFormatFlagDefault},
-	},
+		cli.StringFlag{Name: testCaptureFileFlagName, Usage: "records the session to this capture file"},
+		cli.DurationFlag{Name: testTimeoutFlagName, Value: testTimeoutFlagDefault, Usage: "how long the whole test may take"},
+	),
 	Action: runTest,
 }
 
 type testConfig struct {
-	host      string
-	port      int
-	user      string
-	userToken []byte
+	host        string
+	port        int
+	user        string
+	userToken   []byte
+	captureFile string
+	timeout     time.Duration
+	log         *logging.Logger
 }
 
 func (cfg *testConfig) parse(ctx *cli.Context) error {
 	cfg.host = ctx.String(testHostFlagName)
 	cfg.port = ctx.Int(testPortFlagName)
 	cfg.user = ctx.String(testUserFlagName)
+	cfg.captureFile = ctx.String(testCaptureFileFlagName)
+	cfg.timeout = ctx.Duration(testTimeoutFlagName)
+
+	var err error
+	cfg.log, err = parseLogger(ctx)
+	if err != nil {
+		return err
+	}
 
 	userTokenArg := ctx.String(testUserTokenFlagName)
 	var userToken []byte
@@ -82,19 +101,30 @@ func runTest(ctx *cli.Context) {
 	}
 
 	client := network.NewClient(network.ClientConfig{
-		Host:      cfg.host,
-		Port:      cfg.port,
-		User:      cfg.user,
-		UserToken: cfg.userToken,
+		Host:        cfg.host,
+		Port:        cfg.port,
+		User:        cfg.user,
+		UserToken:   cfg.userToken,
+		CaptureFile: cfg.captureFile,
+		Logger:      cfg.log,
 	})
 
-	err = client.Connect()
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+
+	err = client.Connect(timeoutCtx)
 	if err != nil {
 		panic(err)
 	}
 
-	dragon, err := client.GetOnlineUrDragon()
+	dragon, err := client.GetOnlineUrDragon(timeoutCtx)
 	if err != nil {
+		if protocolErr, ok := err.(*network.ProtocolError); ok {
+			fmt.Printf("the server refused to send the online ur dragon: %s\n", protocolErr.Meaning)
+			client.Disconnect(timeoutCtx)
+			return
+		}
+
 		panic(err)
 	}
 
@@ -104,8 +134,13 @@ func runTest(ctx *cli.Context) {
 	}
 	fmt.Println(string(dragonJson))
 
-	err = client.Disconnect()
+	err = client.Disconnect(timeoutCtx)
 	if err != nil {
+		if protocolErr, ok := err.(*network.ProtocolError); ok {
+			fmt.Printf("the server refused to disconnect: %s\n", protocolErr.Meaning)
+			return
+		}
+
 		panic(err)
 	}
 }
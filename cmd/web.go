@@ -1,69 +1,163 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/atvaark/dragons-dogma-server/modules/admin"
+	"github.com/atvaark/dragons-dogma-server/modules/auth"
 	"github.com/atvaark/dragons-dogma-server/modules/db"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+	"github.com/atvaark/dragons-dogma-server/modules/metrics"
 	"github.com/atvaark/dragons-dogma-server/modules/network"
+	"github.com/atvaark/dragons-dogma-server/modules/scheduler"
 	"github.com/atvaark/dragons-dogma-server/modules/website"
 	"github.com/urfave/cli"
 )
 
 const (
-	webPortFlagName     = "webPort"
-	webSteamKeyFlagName = "webSteamKey"
-	webRootURLFlagName  = "webRootURL"
-	gamePortFlagName    = "gamePort"
-	gameCertFileName    = "gameCertFile"
-	gameKeyFileName     = "gameKeyFile"
-	databaseFileName    = "databaseFile"
-
-	webPortFlagDefault  = 12500
-	webSteamKeyDefault  = ""
-	webRootURLDefault   = "http://localhost"
-	gamePortFlagDefault = 12501
-	gameCertFileDefault = "server.crt"
-	gameKeyFileDefault  = "server.key"
-	databaseFileDefault = "server.db"
+	webPortFlagName        = "webPort"
+	webSteamKeyFlagName    = "webSteamKey"
+	webRootURLFlagName     = "webRootURL"
+	gamePortFlagName       = "gamePort"
+	gameCertFileName       = "gameCertFile"
+	gameKeyFileName        = "gameKeyFile"
+	gameVerifierName       = "gameTokenVerifier"
+	gameSteamAPIURLName    = "gameSteamAPIURL"
+	gameOnlineCheckName    = "gameOnlineCheckInterval"
+	gameOnlineTimeoutName  = "gameOnlineCheckTimeout"
+	gameCaptureFileName    = "gameCaptureFile"
+	gameUnknownDirName     = "gameUnknownPacketDir"
+	gameRedirectName       = "gameRedirect"
+	gameRedirectTargetName = "gameRedirectTarget"
+	databaseFileName       = "databaseFile"
+	dragonTickFlagName     = "dragonTickInterval"
+	metricsPortFlagName    = "metricsPort"
+	adminAPIPortFlagName   = "adminPort"
+	adminAPITokenFlagName  = "adminToken"
+	shutdownTimeoutName    = "shutdownTimeout"
+	maintenanceName        = "maintenance"
+	maintenanceMessageName = "maintenanceMessage"
+	maintenanceKeepName    = "maintenanceKeepSessions"
+
+	webPortFlagDefault        = 12500
+	webSteamKeyDefault        = ""
+	webRootURLDefault         = "http://localhost"
+	gamePortFlagDefault       = 12501
+	gameCertFileDefault       = "server.crt"
+	gameKeyFileDefault        = "server.key"
+	gameVerifierDefault       = "allow"
+	gameSteamAPIURLDefault    = auth.SteamAPIURL
+	gameOnlineCheckDefault    = 60 * time.Second
+	gameOnlineTimeoutDefault  = 30 * time.Second
+	databaseFileDefault       = "server.db"
+	dragonTickDefault         = 1 * time.Minute
+	adminAPIPortDefault       = 12503
+	shutdownTimeoutDefault    = 30 * time.Second
+	maintenanceMessageDefault = "The server is under maintenance. Please try again later."
 )
 
 var WebCommand = cli.Command{
 	Name:        "web",
 	Description: "Starts the server",
-	Flags: []cli.Flag{
+	Flags: withLogFlags(
 		cli.IntFlag{Name: webPortFlagName, Value: webPortFlagDefault},
 		cli.StringFlag{Name: webSteamKeyFlagName, Value: webSteamKeyDefault},
 		cli.StringFlag{Name: webRootURLFlagName, Value: webRootURLDefault},
 		cli.IntFlag{Name: gamePortFlagName, Value: gamePortFlagDefault},
 		cli.StringFlag{Name: gameCertFileName, Value: gameCertFileDefault},
 		cli.StringFlag{Name: gameKeyFileName, Value: gameKeyFileDefault},
+		cli.StringFlag{Name: gameVerifierName, Value: gameVerifierDefault, Usage: "allow, database or steam. database accepts the tokens registered with the admin token command"},
+		cli.StringFlag{Name: gameSteamAPIURLName, Value: gameSteamAPIURLDefault},
+		cli.DurationFlag{Name: gameOnlineCheckName, Value: gameOnlineCheckDefault},
+		cli.DurationFlag{Name: gameOnlineTimeoutName, Value: gameOnlineTimeoutDefault},
+		cli.StringFlag{Name: gameCaptureFileName, Usage: "records all game sessions to this capture file"},
+		cli.StringFlag{Name: gameUnknownDirName, Usage: "archives unknown packets to this directory"},
+		cli.StringFlag{Name: gameRedirectName, Usage: "redirects clients after authentication: static, roundRobin or hash"},
+		cli.StringSliceFlag{Name: gameRedirectTargetName, Usage: "host:port of a server that clients are redirected to, repeatable"},
 		cli.StringFlag{Name: databaseFileName, Value: databaseFileDefault},
-	},
+		cli.DurationFlag{Name: dragonTickFlagName, Value: dragonTickDefault},
+		cli.IntFlag{Name: metricsPortFlagName, Usage: "serves Prometheus metrics on /metrics of this port, 0 disables them"},
+		cli.IntFlag{Name: adminAPIPortFlagName, Value: adminAPIPortDefault},
+		cli.StringFlag{Name: adminAPITokenFlagName, EnvVar: "DDDA_ADMIN_TOKEN", Usage: "enables the admin API, which requires this bearer token"},
+		cli.DurationFlag{Name: shutdownTimeoutName, Value: shutdownTimeoutDefault, Usage: "how long running requests may take before clients are disconnected on shutdown"},
+		cli.BoolFlag{Name: maintenanceName, Usage: "starts in maintenance mode, which SIGUSR1 toggles"},
+		cli.StringFlag{Name: maintenanceMessageName, Value: maintenanceMessageDefault, Usage: "shown to clients that are refused during maintenance"},
+		cli.BoolFlag{Name: maintenanceKeepName, Usage: "lets existing sessions finish when maintenance is enabled"},
+	),
 	Action: runWeb,
 }
 
 type webConfig struct {
-	webPort      int
-	webSteamKey  string
-	webRootURL   string
-	gamePort     int
-	gameCertFile string
-	gameKeyFile  string
-	databaseFile string
+	webPort           int
+	webSteamKey       string
+	webRootURL        string
+	gamePort          int
+	gameCertFile      string
+	gameKeyFile       string
+	gameVerifier      string
+	gameSteamAPI      string
+	gameOnlineCheck   time.Duration
+	gameOnlineTimeout time.Duration
+	gameCaptureFile   string
+	gameUnknownDir    string
+	gameRedirect      string
+	gameRedirectTo    []network.RedirectTarget
+	databaseFile      string
+	dragonTick        time.Duration
+	metricsPort       int
+	adminPort         int
+	adminToken        string
+	shutdownTimeout   time.Duration
+	maintenance       network.Maintenance
+	log               *logging.Logger
 }
 
-func (cfg *webConfig) parse(ctx *cli.Context) {
+func (cfg *webConfig) parse(ctx *cli.Context) error {
 	cfg.webPort = ctx.Int(webPortFlagName)
 	cfg.webSteamKey = ctx.String(webSteamKeyFlagName)
 	cfg.webRootURL = ctx.String(webRootURLFlagName)
 	cfg.gamePort = ctx.Int(gamePortFlagName)
 	cfg.gameCertFile = ctx.String(gameCertFileName)
 	cfg.gameKeyFile = ctx.String(gameKeyFileName)
+	cfg.gameVerifier = ctx.String(gameVerifierName)
+	cfg.gameSteamAPI = ctx.String(gameSteamAPIURLName)
+	cfg.gameOnlineCheck = ctx.Duration(gameOnlineCheckName)
+	cfg.gameOnlineTimeout = ctx.Duration(gameOnlineTimeoutName)
+	cfg.gameCaptureFile = ctx.String(gameCaptureFileName)
+	cfg.gameUnknownDir = ctx.String(gameUnknownDirName)
+	cfg.gameRedirect = ctx.String(gameRedirectName)
+	for _, targetArg := range ctx.StringSlice(gameRedirectTargetName) {
+		target, err := network.ParseRedirectTarget(targetArg)
+		if err != nil {
+			return err
+		}
+		cfg.gameRedirectTo = append(cfg.gameRedirectTo, target)
+	}
 	cfg.databaseFile = ctx.String(databaseFileName)
+	cfg.dragonTick = ctx.Duration(dragonTickFlagName)
+	cfg.metricsPort = ctx.Int(metricsPortFlagName)
+	cfg.adminPort = ctx.Int(adminAPIPortFlagName)
+	cfg.adminToken = ctx.String(adminAPITokenFlagName)
+	cfg.shutdownTimeout = ctx.Duration(shutdownTimeoutName)
+	cfg.maintenance = network.Maintenance{
+		Enabled:      ctx.Bool(maintenanceName),
+		Message:      ctx.String(maintenanceMessageName),
+		KeepSessions: ctx.Bool(maintenanceKeepName),
+	}
+
+	var err error
+	cfg.log, err = parseLogger(ctx)
+	if err != nil {
+		return err
+	}
 
 	if cfg.webRootURL == webRootURLDefault && cfg.webPort != 80 {
 		cfg.webRootURL += fmt.Sprintf(":%d", cfg.webPort)
@@ -73,39 +167,93 @@ func (cfg *webConfig) parse(ctx *cli.Context) {
 		cfg.webRootURL += "/"
 	}
 
+	return nil
 }
 
 func runWeb(ctx *cli.Context) {
 	var cfg webConfig
-	cfg.parse(ctx)
+	err := cfg.parse(ctx)
+	if err != nil {
+		panic(err)
+	}
 
-	log.Println("Starting")
+	log := cfg.log
+	log.Infof("Starting")
 	database := startDatabase(&cfg)
+	dragonScheduler := startScheduler(&cfg, database)
 	gameServer := startGameServer(&cfg, database)
-	gameWebsite := startGameWebsite(&cfg, database)
-	log.Println("Started")
+	gameWebsite := startGameWebsite(&cfg, database, gameServer)
+	metricsServer := startMetricsServer(&cfg)
+	adminAPI := startAdminAPI(&cfg, gameServer, database)
+	log.Infof("Started")
 
 	signalChannel := make(chan os.Signal, 1)
-	signal.Notify(signalChannel, os.Interrupt)
-	for range signalChannel {
-		log.Println("Stopping")
-		var err error
-		err = gameWebsite.Close()
-		if err != nil {
-			log.Println("failed to close website: ", err)
+	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
+	// Notify without signals relays every signal, so only subscribe where maintenance signals exist.
+	if len(maintenanceSignals) > 0 {
+		signal.Notify(signalChannel, maintenanceSignals...)
+	}
+	for sig := range signalChannel {
+		if isMaintenanceSignal(sig) {
+			toggleMaintenance(&cfg, gameServer)
+			continue
+		}
+
+		log.Infof("Stopping")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+		defer cancel()
+
+		// The servers share the shutdown timeout, so they drain at the same time instead of one after another.
+		var shutdown sync.WaitGroup
+		shutdown.Add(1)
+		go func() {
+			defer shutdown.Done()
+			err := gameServer.Shutdown(shutdownCtx)
+			if err != nil {
+				log.Errorf("failed to shut down server: %v", err)
+			}
+		}()
+
+		if adminAPI != nil {
+			err = adminAPI.Close()
+			if err != nil {
+				log.Errorf("failed to close admin API: %v", err)
+			}
 		}
 
-		err = gameServer.Close()
+		if metricsServer != nil {
+			shutdown.Add(1)
+			go func() {
+				defer shutdown.Done()
+				err := metricsServer.Shutdown(shutdownCtx)
+				if err != nil {
+					log.Errorf("failed to close metrics server: %v", err)
+				}
+			}()
+		}
+
+		shutdown.Add(1)
+		go func() {
+			defer shutdown.Done()
+			err := gameWebsite.Shutdown(shutdownCtx)
+			if err != nil {
+				log.Errorf("failed to close website: %v", err)
+			}
+		}()
+
+		shutdown.Wait()
+
+		err = dragonScheduler.Close()
 		if err != nil {
-			log.Println("failed to close server: ", err)
+			log.Errorf("failed to close scheduler: %v", err)
 		}
 
 		err = database.Close()
 		if err != nil {
-			log.Println("failed to close database: ", err)
+			log.Errorf("failed to close database: %v", err)
 		}
 
-		log.Println("Stopped")
+		log.Infof("Stopped")
 
 		return
 	}
@@ -120,11 +268,65 @@ func startDatabase(cfg *webConfig) db.Database {
 	return database
 }
 
+func startScheduler(cfg *webConfig, database db.Database) *scheduler.Scheduler {
+	schedulerConfig := scheduler.SchedulerConfig{
+		TickInterval: cfg.dragonTick,
+		Logger:       cfg.log,
+	}
+
+	dragonScheduler := scheduler.NewScheduler(schedulerConfig, database)
+	dragonScheduler.Start()
+
+	return dragonScheduler
+}
+
 func startGameServer(cfg *webConfig, database db.Database) *network.Server {
+	var verifier network.TokenVerifier
+	switch cfg.gameVerifier {
+	case "allow":
+		verifier = network.AllowAllTokenVerifier{}
+	case "database":
+		if len(cfg.adminToken) == 0 {
+			cfg.log.Warnf("the database token verifier rejects everyone until tokens are registered through the admin API, which needs an admin token")
+		}
+		verifier = network.NewDatabaseTokenVerifier(database)
+	case "steam":
+		verifier = network.NewSteamTokenVerifier(cfg.gameSteamAPI, cfg.webSteamKey)
+	default:
+		panic(fmt.Errorf("unknown token verifier %s", cfg.gameVerifier))
+	}
+
+	var redirectPolicy network.RedirectPolicy
+	switch cfg.gameRedirect {
+	case "":
+	case "static":
+		if len(cfg.gameRedirectTo) != 1 {
+			panic(fmt.Errorf("the static redirect needs exactly one target, got %d", len(cfg.gameRedirectTo)))
+		}
+		redirectPolicy = network.StaticRedirect(cfg.gameRedirectTo[0])
+	case "roundRobin":
+		redirectPolicy = network.RoundRobinRedirect(cfg.gameRedirectTo)
+	case "hash":
+		redirectPolicy = network.HashRedirect(cfg.gameRedirectTo)
+	default:
+		panic(fmt.Errorf("unknown redirect policy %s", cfg.gameRedirect))
+	}
+	if redirectPolicy != nil && len(cfg.gameRedirectTo) == 0 {
+		panic(fmt.Errorf("the %s redirect needs a target", cfg.gameRedirect))
+	}
+
 	srvConfig := network.ServerConfig{
-		Port:     cfg.gamePort,
-		CertFile: cfg.gameCertFile,
-		KeyFile:  cfg.gameKeyFile,
+		Port:                cfg.gamePort,
+		CertFile:            cfg.gameCertFile,
+		KeyFile:             cfg.gameKeyFile,
+		TokenVerifier:       verifier,
+		OnlineCheckInterval: cfg.gameOnlineCheck,
+		OnlineCheckTimeout:  cfg.gameOnlineTimeout,
+		CaptureFile:         cfg.gameCaptureFile,
+		UnknownPacketDir:    cfg.gameUnknownDir,
+		Maintenance:         cfg.maintenance,
+		RedirectPolicy:      redirectPolicy,
+		Logger:              cfg.log,
 	}
 
 	srv, err := network.NewServer(srvConfig, database)
@@ -142,13 +344,18 @@ func startGameServer(cfg *webConfig, database db.Database) *network.Server {
 	return srv
 }
 
-func startGameWebsite(cfg *webConfig, database db.Database) *website.Website {
+func startGameWebsite(cfg *webConfig, database db.Database, gameServer *network.Server) *website.Website {
 	srvConfig := website.WebsiteConfig{
 		RootURL: cfg.webRootURL,
 		Port:    cfg.webPort,
 		AuthConfig: website.AuthConfig{
 			SteamKey: cfg.webSteamKey,
 		},
+		Maintenance: func() (bool, string) {
+			maintenance := gameServer.Maintenance()
+			return maintenance.Enabled, maintenance.Message
+		},
+		Logger: cfg.log,
 	}
 
 	gameWebsite := website.NewWebsite(srvConfig, database)
@@ -162,3 +369,68 @@ func startGameWebsite(cfg *webConfig, database db.Database) *website.Website {
 
 	return gameWebsite
 }
+
+func startMetricsServer(cfg *webConfig) *http.Server {
+	if cfg.metricsPort == 0 {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.DefaultRegistry)
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.metricsPort),
+		Handler: mux,
+	}
+
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+
+	return srv
+}
+
+func startAdminAPI(cfg *webConfig, gameServer *network.Server, database db.Database) *admin.AdminAPI {
+	if len(cfg.adminToken) == 0 {
+		return nil
+	}
+
+	adminAPI, err := admin.NewAdminAPI(admin.AdminAPIConfig{
+		Port:       cfg.adminPort,
+		Token:      cfg.adminToken,
+		GameTokens: database,
+		Logger:     cfg.log,
+	}, gameServer)
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		err := adminAPI.ListenAndServe()
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	return adminAPI
+}
+
+// toggleMaintenance switches the maintenance mode with the message and session handling of the flags.
+func toggleMaintenance(cfg *webConfig, gameServer *network.Server) {
+	maintenance := cfg.maintenance
+	maintenance.Enabled = !gameServer.Maintenance().Enabled
+	gameServer.SetMaintenance(maintenance)
+}
+
+func isMaintenanceSignal(sig os.Signal) bool {
+	for _, maintenanceSignal := range maintenanceSignals {
+		if sig == maintenanceSignal {
+			return true
+		}
+	}
+
+	return false
+}
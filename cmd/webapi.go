@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/atvaark/dragons-dogma-server/modules/api"
 	"github.com/urfave/cli"
@@ -16,25 +17,34 @@ const (
 	apiUserFlagName            = "user"
 	apiUserTokenFlagName       = "token"
 	apiUserTokenFormatFlagName = "tokenFormat"
+	apiTimeoutFlagName         = "timeout"
+	apiPoolSizeFlagName        = "poolSize"
+	apiKeepAliveFlagName       = "keepAlive"
 
 	apiPortFlagDefault = 12502
 
 	apiServerHostFlagDefault      = "dune.dragonsdogma.com"
 	apiServerPortFlagDefault      = 12501
 	apiUserTokenFormatFlagDefault = "base64"
+	apiTimeoutFlagDefault         = 30 * time.Second
+	apiPoolSizeFlagDefault        = 1
+	apiKeepAliveFlagDefault       = 30 * time.Second
 )
 
 var ApiCommand = cli.Command{
 	Name:        "api",
 	Description: "Hosts a server that exposes a JSON endpoint for the ur dragon status .",
-	Flags: []cli.Flag{
+	Flags: withLogFlags(
 		cli.IntFlag{Name: apiPortFlagName, Value: apiPortFlagDefault},
 		cli.StringFlag{Name: apiServerHostFlagName, Value: apiServerHostFlagDefault},
 		cli.IntFlag{Name: apiServerPortFlagName, Value: apiServerPortFlagDefault},
 		cli.StringFlag{Name: apiUserFlagName},
 		cli.StringFlag{Name: apiUserTokenFlagName},
 		cli.StringFlag{Name: apiUserTokenFormatFlagName, Value: apiUserTokenFormatFlagDefault},
-	},
+		cli.DurationFlag{Name: apiTimeoutFlagName, Value: apiTimeoutFlagDefault, Usage: "how long fetching the dragon from the game server may take"},
+		cli.IntFlag{Name: apiPoolSizeFlagName, Value: apiPoolSizeFlagDefault, Usage: "sessions kept open to the game server, more than 1 only adds failover sessions"},
+		cli.DurationFlag{Name: apiKeepAliveFlagName, Value: apiKeepAliveFlagDefault, Usage: "idle time after which a session sends an online check"},
+	),
 	Action: runApi,
 }
 
@@ -44,6 +54,15 @@ func parseConfig(ctx *cli.Context) (api.DragonAPIConfig, error) {
 	cfg.ServerHost = ctx.String(apiServerHostFlagName)
 	cfg.ServerPort = ctx.Int(apiServerPortFlagName)
 	cfg.User = ctx.String(apiUserFlagName)
+	cfg.Timeout = ctx.Duration(apiTimeoutFlagName)
+	cfg.PoolSize = ctx.Int(apiPoolSizeFlagName)
+	cfg.KeepAliveInterval = ctx.Duration(apiKeepAliveFlagName)
+
+	var err error
+	cfg.Logger, err = parseLogger(ctx)
+	if err != nil {
+		return cfg, err
+	}
 
 	userTokenArg := ctx.String(apiUserTokenFlagName)
 	var userToken []byte
@@ -18,6 +18,10 @@ func main() {
 		cmd.WebCommand,
 		cmd.TestCommand,
 		cmd.ApiCommand,
+		cmd.ReplayCommand,
+		cmd.ProxyCommand,
+		cmd.DissectCommand,
+		cmd.AdminCommand,
 	}
 
 	app.Run(os.Args)
@@ -0,0 +1,316 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/atvaark/dragons-dogma-server/modules/auth"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+)
+
+// ConnectionManager is the part of network.Server that the admin API controls.
+type ConnectionManager interface {
+	Connections() []network.ConnectionInfo
+	Kick(connID int64, message string) error
+	Broadcast(notification network.Packet) (int, error)
+	Maintenance() network.Maintenance
+	SetMaintenance(maintenance network.Maintenance) int
+}
+
+type AdminAPIConfig struct {
+	Port  int
+	Token string
+	// GameTokens stores the tokens that the database token verifier accepts. Without it tokens can not be registered.
+	GameTokens auth.TokenDatabase
+	Logger     *logging.Logger
+}
+
+// AdminAPI serves the connection controls over HTTP. Every request needs the token as a bearer token.
+type AdminAPI struct {
+	server *http.Server
+	log    *logging.Logger
+}
+
+const (
+	connectionsPath = "/connections"
+	broadcastPath   = "/broadcast"
+	maintenancePath = "/maintenance"
+	gameTokensPath  = "/tokens"
+	kickSuffix      = "/kick"
+
+	disconnectionNotificationType = "disconnection"
+	reconnectionNotificationType  = "reconnection"
+)
+
+type kickRequest struct {
+	Message string `json:"message"`
+}
+
+type broadcastRequest struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Port    uint16 `json:"port,omitempty"`
+}
+
+type broadcastResponse struct {
+	Sent int `json:"sent"`
+}
+
+type maintenanceResponse struct {
+	network.Maintenance
+	Disconnected int `json:"disconnected"`
+}
+
+type gameTokenRequest struct {
+	Token []byte `json:"token"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewAdminAPI(cfg AdminAPIConfig, connections ConnectionManager) (*AdminAPI, error) {
+	if len(cfg.Token) == 0 {
+		return nil, errors.New("the admin API requires a token")
+	}
+
+	log := cfg.Logger.WithField("component", "admin")
+	h := &adminHandler{
+		token:       []byte(cfg.Token),
+		connections: connections,
+		gameTokens:  cfg.GameTokens,
+		log:         log,
+	}
+
+	return &AdminAPI{
+		server: &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.Port),
+			Handler: h,
+		},
+		log: log,
+	}, nil
+}
+
+func (a *AdminAPI) ListenAndServe() error {
+	a.log.Infof("Listening on %s", a.server.Addr)
+	err := a.server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AdminAPI) Close() error {
+	return a.server.Close()
+}
+
+type adminHandler struct {
+	token       []byte
+	connections ConnectionManager
+	gameTokens  auth.TokenDatabase
+	log         *logging.Logger
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		h.log.WithField("remoteAddress", r.RemoteAddr).Warnf("unauthorized %s %s", r.Method, r.URL.Path)
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == connectionsPath:
+		h.handleConnections(w, r)
+	case path == broadcastPath:
+		h.handleBroadcast(w, r)
+	case path == maintenancePath:
+		h.handleMaintenance(w, r)
+	case strings.HasPrefix(path, gameTokensPath+"/"):
+		h.handleGameToken(w, r, strings.TrimPrefix(path, gameTokensPath+"/"))
+	case strings.HasPrefix(path, connectionsPath+"/") && strings.HasSuffix(path, kickSuffix):
+		h.handleKick(w, r, strings.TrimSuffix(strings.TrimPrefix(path, connectionsPath+"/"), kickSuffix))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *adminHandler) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, prefix)), h.token) == 1
+}
+
+func (h *adminHandler) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.connections.Connections())
+}
+
+func (h *adminHandler) handleKick(w http.ResponseWriter, r *http.Request, connIDArg string) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+
+	connID, err := strconv.ParseInt(connIDArg, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid connection id %s", connIDArg))
+		return
+	}
+
+	var request kickRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	err = h.connections.Kick(connID, request.Message)
+	if err == network.ErrConnectionNotFound {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.log.WithField("conn", connID).Warnf("kick failed: %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.log.WithField("conn", connID).Infof("kicked by %s", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *adminHandler) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+
+	var request broadcastRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	if len(request.Type) == 0 {
+		request.Type = disconnectionNotificationType
+	}
+
+	var notification network.Packet
+	switch request.Type {
+	case disconnectionNotificationType:
+		notification = &network.DisconnectionNotification{Notification: request.Message}
+	case reconnectionNotificationType:
+		if len(request.Host) == 0 || request.Port == 0 {
+			writeError(w, http.StatusBadRequest, "a reconnection needs a host and a port")
+			return
+		}
+		notification = &network.ReconnectionNotification{Host: request.Host, Port: request.Port}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown notification type %s", request.Type))
+		return
+	}
+
+	sent, err := h.connections.Broadcast(notification)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.log.Infof("broadcast %s notification to %d clients", request.Type, sent)
+	writeJSON(w, http.StatusOK, broadcastResponse{Sent: sent})
+}
+
+func (h *adminHandler) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, maintenanceResponse{Maintenance: h.connections.Maintenance()})
+	case http.MethodPut:
+		var maintenance network.Maintenance
+		if !readJSON(w, r, &maintenance) {
+			return
+		}
+
+		disconnected := h.connections.SetMaintenance(maintenance)
+		h.log.WithField("enabled", maintenance.Enabled).Infof("maintenance changed by %s", r.RemoteAddr)
+		writeJSON(w, http.StatusOK, maintenanceResponse{Maintenance: maintenance, Disconnected: disconnected})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "use GET or PUT")
+	}
+}
+
+func (h *adminHandler) handleGameToken(w http.ResponseWriter, r *http.Request, user string) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "use PUT")
+		return
+	}
+
+	if h.gameTokens == nil {
+		writeError(w, http.StatusNotFound, "the server has no token database")
+		return
+	}
+
+	if len(user) == 0 || strings.Contains(user, "/") {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid user %s", user))
+		return
+	}
+
+	var request gameTokenRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	if len(request.Token) == 0 {
+		writeError(w, http.StatusBadRequest, "missing token")
+		return
+	}
+
+	err := h.gameTokens.PutGameToken(user, request.Token)
+	if err != nil {
+		h.log.WithField("user", user).Warnf("failed to register the game token: %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.log.WithField("user", user).Infof("game token registered by %s", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == io.EOF {
+		return true
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+)
+
+type fakeConnections struct {
+	kicked      map[int64]string
+	broadcast   []network.Packet
+	maintenance network.Maintenance
+}
+
+func (f *fakeConnections) Connections() []network.ConnectionInfo {
+	return []network.ConnectionInfo{{ID: 1, User: "user"}}
+}
+
+func (f *fakeConnections) Kick(connID int64, message string) error {
+	if connID != 1 {
+		return network.ErrConnectionNotFound
+	}
+
+	f.kicked[connID] = message
+	return nil
+}
+
+func (f *fakeConnections) Broadcast(notification network.Packet) (int, error) {
+	f.broadcast = append(f.broadcast, notification)
+	return 1, nil
+}
+
+func (f *fakeConnections) Maintenance() network.Maintenance {
+	return f.maintenance
+}
+
+func (f *fakeConnections) SetMaintenance(maintenance network.Maintenance) int {
+	f.maintenance = maintenance
+	return 2
+}
+
+func newTestAPI(t *testing.T) (*fakeConnections, *httptest.Server) {
+	connections := &fakeConnections{kicked: make(map[int64]string)}
+	api, err := NewAdminAPI(AdminAPIConfig{Token: "secret"}, connections)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return connections, httptest.NewServer(api.server.Handler)
+}
+
+func TestClient(t *testing.T) {
+	connections, server := newTestAPI(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret")
+
+	infos, err := client.Connections()
+	if err != nil || len(infos) != 1 || infos[0].User != "user" {
+		t.Errorf("unexpected connections %v %v", infos, err)
+	}
+
+	err = client.Kick(1, "bye")
+	if err != nil || connections.kicked[1] != "bye" {
+		t.Errorf("unexpected kick %v %v", connections.kicked, err)
+	}
+
+	err = client.Kick(2, "")
+	if err == nil || !strings.Contains(err.Error(), "connection not found") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	sent, err := client.BroadcastReconnection("localhost", 12501)
+	if err != nil || sent != 1 {
+		t.Errorf("unexpected broadcast %d %v", sent, err)
+	}
+
+	reconnection, ok := connections.broadcast[0].(*network.ReconnectionNotification)
+	if !ok || reconnection.Host != "localhost" || reconnection.Port != 12501 {
+		t.Errorf("unexpected notification %v", connections.broadcast[0])
+	}
+}
+
+func TestInvalidToken(t *testing.T) {
+	_, server := newTestAPI(t)
+	defer server.Close()
+
+	_, err := NewClient(server.URL, "guess").Connections()
+	if err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	response, err := http.Get(server.URL + connectionsPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected status %s", response.Status)
+	}
+}
+
+func TestMissingToken(t *testing.T) {
+	_, err := NewAdminAPI(AdminAPIConfig{}, &fakeConnections{})
+	if err == nil {
+		t.Error("admin API started without a token")
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	connections, server := newTestAPI(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret")
+
+	disconnected, err := client.SetMaintenance(network.Maintenance{Enabled: true, Message: "database work"})
+	if err != nil || disconnected != 2 || !connections.maintenance.Enabled {
+		t.Errorf("unexpected result %d %v %+v", disconnected, err, connections.maintenance)
+	}
+
+	maintenance, err := client.Maintenance()
+	if err != nil || !maintenance.Enabled || maintenance.Message != "database work" {
+		t.Errorf("unexpected maintenance %+v %v", maintenance, err)
+	}
+}
+
+type fakeGameTokens map[string][]byte
+
+func (f fakeGameTokens) GetGameToken(user string) ([]byte, error) {
+	return f[user], nil
+}
+
+func (f fakeGameTokens) PutGameToken(user string, token []byte) error {
+	f[user] = token
+	return nil
+}
+
+func TestPutGameToken(t *testing.T) {
+	tokens := fakeGameTokens{}
+	api, err := NewAdminAPI(AdminAPIConfig{Token: "secret", GameTokens: tokens}, &fakeConnections{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(api.server.Handler)
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret")
+
+	err = client.PutGameToken("0110000100000001", []byte{1, 2, 3})
+	if err != nil || string(tokens["0110000100000001"]) != "\x01\x02\x03" {
+		t.Errorf("token was not registered %v %v", tokens, err)
+	}
+
+	err = client.PutGameToken("0110000100000001", nil)
+	if err == nil || !strings.Contains(err.Error(), "missing token") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, withoutTokens := newTestAPI(t)
+	defer withoutTokens.Close()
+
+	err = NewClient(withoutTokens.URL, "secret").PutGameToken("0110000100000001", []byte{1})
+	if err == nil || !strings.Contains(err.Error(), "no token database") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+)
+
+const clientTimeout = 30 * time.Second
+
+// Client calls the admin API of a running server.
+type Client struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+func NewClient(url string, token string) *Client {
+	return &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: clientTimeout},
+	}
+}
+
+func (c *Client) Connections() ([]network.ConnectionInfo, error) {
+	var connections []network.ConnectionInfo
+	err := c.do(http.MethodGet, connectionsPath, nil, &connections)
+	if err != nil {
+		return nil, err
+	}
+
+	return connections, nil
+}
+
+func (c *Client) Kick(connID int64, message string) error {
+	return c.do(http.MethodPost, fmt.Sprintf("%s/%d%s", connectionsPath, connID, kickSuffix), kickRequest{Message: message}, nil)
+}
+
+// BroadcastDisconnection tells every client to disconnect and shows them the message.
+func (c *Client) BroadcastDisconnection(message string) (int, error) {
+	return c.broadcast(broadcastRequest{Type: disconnectionNotificationType, Message: message})
+}
+
+// BroadcastReconnection tells every client to reconnect to another server.
+func (c *Client) BroadcastReconnection(host string, port uint16) (int, error) {
+	return c.broadcast(broadcastRequest{Type: reconnectionNotificationType, Host: host, Port: port})
+}
+
+func (c *Client) broadcast(request broadcastRequest) (int, error) {
+	var response broadcastResponse
+	err := c.do(http.MethodPost, broadcastPath, request, &response)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.Sent, nil
+}
+
+func (c *Client) Maintenance() (network.Maintenance, error) {
+	var response maintenanceResponse
+	err := c.do(http.MethodGet, maintenancePath, nil, &response)
+	return response.Maintenance, err
+}
+
+// SetMaintenance returns the number of sessions that the server disconnected.
+func (c *Client) SetMaintenance(maintenance network.Maintenance) (int, error) {
+	var response maintenanceResponse
+	err := c.do(http.MethodPut, maintenancePath, maintenance, &response)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.Disconnected, nil
+}
+
+// PutGameToken registers the token that the user has to authenticate with when the server verifies tokens against its database.
+func (c *Client) PutGameToken(user string, token []byte) error {
+	return c.do(http.MethodPut, gameTokensPath+"/"+url.PathEscape(user), gameTokenRequest{Token: token}, nil)
+}
+
+func (c *Client) do(method string, path string, body interface{}, result interface{}) error {
+	var requestBody bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&requestBody).Encode(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequest(method, c.url+path, &requestBody)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.token)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		var errResponse errorResponse
+		err = json.NewDecoder(response.Body).Decode(&errResponse)
+		if err != nil || len(errResponse.Error) == 0 {
+			return fmt.Errorf("admin API returned %s", response.Status)
+		}
+
+		return fmt.Errorf("admin API returned %s: %s", response.Status, errResponse.Error)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
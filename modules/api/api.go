@@ -1,15 +1,17 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/atvaark/dragons-dogma-server/modules/game"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
 	"github.com/atvaark/dragons-dogma-server/modules/network"
 )
 
@@ -19,54 +21,117 @@ type DragonAPIConfig struct {
 	ServerPort int
 	User       string
 	UserToken  []byte
+	// Timeout bounds fetching the dragon from the game server. Zero uses defaultTimeout.
+	Timeout time.Duration
+	// PoolSize is the number of sessions that are kept open to the game server. Zero uses defaultPoolSize.
+	// Callers share one fetch through the response cache, so sessions beyond the first are only for failover
+	// while another session reconnects. Each of them sends its own keep-alive traffic.
+	PoolSize int
+	// KeepAliveInterval is how long a session may idle before it sends an online check.
+	// It has to be shorter than the online check interval of the game server. Zero uses defaultKeepAliveInterval.
+	KeepAliveInterval time.Duration
+	Logger            *logging.Logger
+	dial              func(ctx context.Context, address string) (net.Conn, error)
 }
 
+const (
+	defaultTimeout           = 30 * time.Second
+	defaultPoolSize          = 1
+	defaultKeepAliveInterval = 30 * time.Second
+)
+
 type DragonAPI struct {
 	handler dragonAPIHandler
+	server  *http.Server
 }
 
 func NewDragonAPI(cfg DragonAPIConfig) *DragonAPI {
-	return &DragonAPI{
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = defaultPoolSize
+	}
+
+	if cfg.KeepAliveInterval <= 0 {
+		cfg.KeepAliveInterval = defaultKeepAliveInterval
+	}
+
+	log := cfg.Logger.WithField("component", "api")
+	d := &DragonAPI{
 		handler: dragonAPIHandler{
-			cfg: cfg,
+			cfg:  cfg,
+			log:  log,
+			pool: newClientPool(cfg, log),
 			cache: responseCache{
 				cacheDuration: 1 * time.Minute,
 			},
 		},
 	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.handler.handle)
+	mux.HandleFunc("/status", d.handler.handleStatus)
+	d.server = &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: mux,
+	}
+
+	return d
 }
 
 func (d *DragonAPI) ListenAndServe() error {
-	log.Printf("[API] Starting\n")
+	log := d.handler.log
+	log.Infof("Starting")
 
-	log.Printf("[API] Testing connection to the game server\n")
-	_, err := d.handler.fetchResponse()
+	log.Infof("Testing connection to the game server")
+	_, err := d.handler.fetchResponse(context.Background())
 	if err != nil {
 		return err
 	}
-	log.Printf("[API] Connection to the game server OK\n")
+	log.Infof("Connection to the game server OK")
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", d.handler.handle)
-	addr := fmt.Sprintf(":%d", d.handler.cfg.Port)
-	log.Printf("[API] Listening on %s\n", addr)
-	err = http.ListenAndServe(addr, mux)
-	if err != nil {
+	d.handler.pool.start()
+
+	log.Infof("Listening on %s", d.server.Addr)
+	err = d.server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
+// Close stops serving and disconnects the sessions to the game server.
+func (d *DragonAPI) Close() error {
+	err := d.server.Close()
+	d.handler.pool.close()
+	return err
+}
+
 type dragonAPIHandler struct {
 	cfg   DragonAPIConfig
+	log   *logging.Logger
+	pool  *clientPool
 	cache responseCache
 }
 
 func (h *dragonAPIHandler) handle(w http.ResponseWriter, r *http.Request) {
-	dragonResponse, err := h.fetchResponse()
+	dragonResponse, err := h.fetchResponse(r.Context())
 	if err != nil {
 		const getError = "dragon status couldn't be determined"
-		log.Printf("%s: %v", getError, err)
+		h.log.WithField("remoteAddress", r.RemoteAddr).Warnf("%s: %v", getError, err)
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, fmt.Sprintf("%s: the game server did not answer in time", getError), http.StatusGatewayTimeout)
+			return
+		}
+
+		if protocolErr, ok := err.(*network.ProtocolError); ok {
+			http.Error(w, fmt.Sprintf("%s: the game server refused the request: %s", getError, protocolErr.Meaning), http.StatusBadGateway)
+			return
+		}
+
 		http.Error(w, getError, http.StatusInternalServerError)
 		return
 	}
@@ -77,52 +142,39 @@ func (h *dragonAPIHandler) handle(w http.ResponseWriter, r *http.Request) {
 	enc.Encode(dragonResponse)
 }
 
-func (h *dragonAPIHandler) fetchResponse() (*dragonResponse, error) {
+// handleStatus reports the sessions of the pool and the last upstream error.
+// It answers with 503 while no session is connected.
+func (h *dragonAPIHandler) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := h.pool.status()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(status)
+}
+
+func (h *dragonAPIHandler) fetchResponse(ctx context.Context) (*dragonResponse, error) {
 	response, err := h.cache.GetResponse()
 	if err == nil {
 		return response, nil
 	}
 
-	response, err = h.cache.UpdateResult(h.fetchNewResponse)
-	if err != nil {
-		return nil, err
-	}
-
-	return response, nil
+	return h.cache.UpdateResult(ctx, h.fetchNewResponse)
 }
 
-func (h *dragonAPIHandler) fetchNewResponse() (*dragonResponse, error) {
-	dragon, err := h.getDragon()
+func (h *dragonAPIHandler) fetchNewResponse(ctx context.Context) (*dragonResponse, error) {
+	dragon, err := h.getDragon(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return mapToResponse(dragon), nil
 }
 
-func (h *dragonAPIHandler) getDragon() (*game.OnlineUrDragon, error) {
-	client := network.NewClient(network.ClientConfig{
-		Host:      h.cfg.ServerHost,
-		Port:      h.cfg.ServerPort,
-		User:      h.cfg.User,
-		UserToken: h.cfg.UserToken,
-	})
-
-	err := client.Connect()
-	if err != nil {
-		return nil, err
-	}
-
-	dragon, err := client.GetOnlineUrDragon()
-	if err != nil {
-		return nil, err
-	}
-
-	err = client.Disconnect()
-	if err != nil {
-		return nil, err
-	}
-
-	return dragon, nil
+func (h *dragonAPIHandler) getDragon(ctx context.Context) (*game.OnlineUrDragon, error) {
+	return h.pool.getDragon(ctx)
 }
 
 type dragonResponse struct {
@@ -174,34 +226,63 @@ func mapToResponse(dragon *game.OnlineUrDragon) *dragonResponse {
 type responseCache struct {
 	response          *dragonResponse
 	responseFetchTime time.Time
+	fetch             *responseFetch
 	mutex             sync.RWMutex
 	cacheDuration     time.Duration
 }
 
+// responseFetch is a fetch from the game server that every caller waits for while the cache is stale.
+type responseFetch struct {
+	done     chan struct{}
+	response *dragonResponse
+	err      error
+}
+
 func (c *responseCache) GetResponse() (*dragonResponse, error) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	return c.getResponseInternal()
 }
 
-func (c *responseCache) UpdateResult(fetchNewFunc func() (*dragonResponse, error)) (*dragonResponse, error) {
+// UpdateResult starts a fetch unless one is already running and waits until it finishes or ctx ends.
+// The fetch runs without the lock and without the caller's context, the pool timeout bounds it,
+// so a slow game server only delays the callers that need the new response.
+func (c *responseCache) UpdateResult(ctx context.Context, fetchNewFunc func(context.Context) (*dragonResponse, error)) (*dragonResponse, error) {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
 	response, err := c.getResponseInternal()
 	if err == nil {
+		c.mutex.Unlock()
 		return response, nil
 	}
 
-	response, err = fetchNewFunc()
-	if err != nil {
-		return nil, err
+	fetch := c.fetch
+	if fetch == nil {
+		fetch = &responseFetch{done: make(chan struct{})}
+		c.fetch = fetch
+		go c.runFetch(fetch, fetchNewFunc)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.response, fetch.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
+}
 
-	c.response = response
-	c.responseFetchTime = time.Now()
+func (c *responseCache) runFetch(fetch *responseFetch, fetchNewFunc func(context.Context) (*dragonResponse, error)) {
+	fetch.response, fetch.err = fetchNewFunc(context.Background())
+
+	c.mutex.Lock()
+	if fetch.err == nil {
+		c.response = fetch.response
+		c.responseFetchTime = time.Now()
+	}
+	c.fetch = nil
+	c.mutex.Unlock()
 
-	return response, nil
+	close(fetch.done)
 }
 
 func (c *responseCache) getResponseInternal() (*dragonResponse, error) {
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+)
+
+type dragonDatabase struct {
+	mutex  sync.Mutex
+	dragon *game.OnlineUrDragon
+}
+
+func (db *dragonDatabase) GetOnlineUrDragon() (*game.OnlineUrDragon, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	d := *db.dragon
+	return &d, nil
+}
+
+func (db *dragonDatabase) PutOnlineUrDragon(dragon *game.OnlineUrDragon) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.dragon = dragon
+	return nil
+}
+
+func (db *dragonDatabase) UpdateOnlineUrDragon(update func(*game.OnlineUrDragon) error) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return update(db.dragon)
+}
+
+func (db *dragonDatabase) GetPawnRewards(userID uint64) (*game.PawnRewards, error) {
+	return nil, nil
+}
+
+func (db *dragonDatabase) PutPawnRewards(rewards *game.PawnRewards) error {
+	return nil
+}
+
+// loopbackDialer connects to the in-process server over loopback TCP.
+func loopbackDialer(s *network.Server) func(ctx context.Context, address string) (net.Conn, error) {
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer listener.Close()
+
+		clientSide, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+
+		serverSide, err := listener.Accept()
+		if err != nil {
+			clientSide.Close()
+			return nil, err
+		}
+
+		go s.ServeConn(serverSide)
+		return clientSide, nil
+	}
+}
+
+func TestPooledSessions(t *testing.T) {
+	database := &dragonDatabase{dragon: (&game.OnlineUrDragon{}).NextGeneration()}
+	s := network.NewInProcessServer(network.ServerConfig{}, database)
+
+	d := NewDragonAPI(DragonAPIConfig{
+		User:              "0110000100000001",
+		KeepAliveInterval: 10 * time.Millisecond,
+		dial:              loopbackDialer(s),
+	})
+	defer d.Close()
+	h := &d.handler
+	h.cache.cacheDuration = 0
+
+	status := httptest.NewRecorder()
+	h.handleStatus(status, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if status.Code != http.StatusServiceUnavailable {
+		t.Errorf("unconnected pool is healthy: %d", status.Code)
+	}
+
+	var sessionID int64
+	for i := 0; i < 3; i++ {
+		response := httptest.NewRecorder()
+		h.handle(response, httptest.NewRequest(http.MethodGet, "/", nil))
+		if response.Code != http.StatusOK {
+			t.Errorf("request %d failed: %d %s", i, response.Code, response.Body.String())
+			return
+		}
+
+		connections := s.Connections()
+		if len(connections) != 1 || (sessionID != 0 && connections[0].ID != sessionID) {
+			t.Errorf("request %d did not reuse the session: %v", i, connections)
+			return
+		}
+		sessionID = connections[0].ID
+	}
+
+	h.pool.start()
+	time.Sleep(50 * time.Millisecond)
+
+	if connections := s.Connections(); len(connections) != 1 || connections[0].LastPacket != "onlineCheck request" {
+		t.Errorf("idle session did not send an online check: %v", connections)
+	}
+
+	status = httptest.NewRecorder()
+	h.handleStatus(status, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var poolStatus poolStatus
+	err := json.NewDecoder(status.Body).Decode(&poolStatus)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if status.Code != http.StatusOK || !poolStatus.Healthy || len(poolStatus.Connections) != 1 || poolStatus.Connections[0].State != "connected" {
+		t.Errorf("unexpected status %d %+v", status.Code, poolStatus)
+	}
+}
+
+func TestResponseCacheSharesFetch(t *testing.T) {
+	cache := responseCache{cacheDuration: time.Minute}
+	release := make(chan struct{})
+	var fetches int32
+	fetch := func(ctx context.Context) (*dragonResponse, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return &dragonResponse{Generation: 1}, nil
+	}
+
+	results := make(chan *dragonResponse, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			response, _ := cache.UpdateResult(context.Background(), fetch)
+			results <- response
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := cache.UpdateResult(ctx, fetch)
+	if err != context.DeadlineExceeded {
+		t.Errorf("waiting caller did not give up with its context: %v", err)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if response := <-results; response == nil || response.Generation != 1 {
+			t.Errorf("unexpected response %v", response)
+		}
+	}
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("fetch count mismatch %d %d", n, 1)
+	}
+
+	response, err := cache.GetResponse()
+	if err != nil || response.Generation != 1 {
+		t.Errorf("fetched response was not cached: %v", err)
+	}
+}
+
+func TestStalledFetchTimesOut(t *testing.T) {
+	database := &dragonDatabase{dragon: (&game.OnlineUrDragon{}).NextGeneration()}
+	s := network.NewInProcessServer(network.ServerConfig{}, database)
+	release := make(chan struct{})
+	defer close(release)
+	s.HandleFunc(network.GetPacketType(&network.TusCommonAreaAcquisitionRequest{}).NameID, func(client *network.ClientConn, request network.Packet) error {
+		<-release
+		return network.ErrClientDisconnected
+	})
+
+	d := NewDragonAPI(DragonAPIConfig{
+		User:    "0110000100000001",
+		Timeout: 50 * time.Millisecond,
+		dial:    loopbackDialer(s),
+	})
+	defer d.Close()
+
+	response := httptest.NewRecorder()
+	d.handler.handle(response, httptest.NewRequest(http.MethodGet, "/", nil))
+	if response.Code != http.StatusGatewayTimeout {
+		t.Errorf("stalled fetch status mismatch %d %d: %s", response.Code, http.StatusGatewayTimeout, response.Body.String())
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+	"github.com/atvaark/dragons-dogma-server/modules/network"
+)
+
+// clientPool keeps long-lived sessions to the game server. Its clients reconnect on their own
+// and send online checks while they are idle, so that the game server does not time them out.
+type clientPool struct {
+	clients   []*pooledClient
+	next      uint32
+	keepAlive time.Duration
+	timeout   time.Duration
+	log       *logging.Logger
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+
+	errorMutex  sync.Mutex
+	lastError   error
+	lastErrorAt time.Time
+}
+
+type pooledClient struct {
+	id     int
+	client *network.Client
+
+	mutex      sync.Mutex
+	state      network.ClientState
+	stateSince time.Time
+	lastError  error
+	lastUsed   time.Time
+}
+
+type poolStatus struct {
+	Healthy     bool               `json:"healthy"`
+	Connections []connectionStatus `json:"connections"`
+	LastError   string             `json:"lastError,omitempty"`
+	LastErrorAt *time.Time         `json:"lastErrorAt,omitempty"`
+}
+
+type connectionStatus struct {
+	ID        int        `json:"id"`
+	State     string     `json:"state"`
+	Since     time.Time  `json:"since"`
+	LastError string     `json:"lastError,omitempty"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+}
+
+func newClientPool(cfg DragonAPIConfig, log *logging.Logger) *clientPool {
+	p := &clientPool{
+		clients:   make([]*pooledClient, cfg.PoolSize),
+		keepAlive: cfg.KeepAliveInterval,
+		timeout:   cfg.Timeout,
+		log:       log,
+		stop:      make(chan struct{}),
+	}
+
+	for i := range p.clients {
+		pc := &pooledClient{
+			id:         i,
+			stateSince: time.Now().UTC(),
+		}
+		pc.client = network.NewClient(network.ClientConfig{
+			Host:          cfg.ServerHost,
+			Port:          cfg.ServerPort,
+			User:          cfg.User,
+			UserToken:     cfg.UserToken,
+			Logger:        log.WithField("pool", i),
+			Dial:          cfg.dial,
+			Resilient:     true,
+			OnStateChange: pc.setState,
+		})
+		p.clients[i] = pc
+	}
+
+	return p
+}
+
+func (pc *pooledClient) setState(state network.ClientState, err error) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if pc.state != state {
+		pc.state = state
+		pc.stateSince = time.Now().UTC()
+	}
+	if err != nil {
+		pc.lastError = err
+	}
+}
+
+// start runs the keep alive of every client until close.
+func (p *clientPool) start() {
+	for _, pc := range p.clients {
+		p.stopped.Add(1)
+		go p.keepAliveLoop(pc)
+	}
+}
+
+func (p *clientPool) keepAliveLoop(pc *pooledClient) {
+	defer p.stopped.Done()
+
+	ticker := time.NewTicker(p.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		pc.mutex.Lock()
+		idle := time.Since(pc.lastUsed) >= p.keepAlive
+		pc.mutex.Unlock()
+		if !idle {
+			continue
+		}
+
+		p.use(context.Background(), pc, pc.client.OnlineCheck)
+	}
+}
+
+// use runs the call on the client within the pool timeout. The call is also cancelled when the pool is closed.
+func (p *clientPool) use(ctx context.Context, pc *pooledClient, call func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	err := call(ctx)
+
+	pc.mutex.Lock()
+	pc.lastUsed = time.Now().UTC()
+	pc.mutex.Unlock()
+
+	if err != nil {
+		p.recordError(err)
+	}
+
+	return err
+}
+
+func (p *clientPool) recordError(err error) {
+	p.errorMutex.Lock()
+	defer p.errorMutex.Unlock()
+	p.lastError = err
+	p.lastErrorAt = time.Now().UTC()
+}
+
+// getDragon fetches the dragon with the next client of the pool.
+func (p *clientPool) getDragon(ctx context.Context) (*game.OnlineUrDragon, error) {
+	pc := p.clients[int((atomic.AddUint32(&p.next, 1)-1)%uint32(len(p.clients)))]
+
+	var dragon *game.OnlineUrDragon
+	err := p.use(ctx, pc, func(ctx context.Context) error {
+		var err error
+		dragon, err = pc.client.GetOnlineUrDragon(ctx)
+		return err
+	})
+	if err != nil {
+		p.log.WithField("pool", pc.id).Warnf("failed to get the dragon: %v", err)
+		return nil, err
+	}
+
+	return dragon, nil
+}
+
+func (p *clientPool) status() poolStatus {
+	var status poolStatus
+	for _, pc := range p.clients {
+		pc.mutex.Lock()
+		connection := connectionStatus{
+			ID:    pc.id,
+			State: pc.state.String(),
+			Since: pc.stateSince,
+		}
+		if pc.lastError != nil {
+			connection.LastError = pc.lastError.Error()
+		}
+		if !pc.lastUsed.IsZero() {
+			lastUsed := pc.lastUsed
+			connection.LastUsed = &lastUsed
+		}
+		if pc.state == network.ClientConnected {
+			status.Healthy = true
+		}
+		pc.mutex.Unlock()
+
+		status.Connections = append(status.Connections, connection)
+	}
+
+	p.errorMutex.Lock()
+	if p.lastError != nil {
+		status.LastError = p.lastError.Error()
+		lastErrorAt := p.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	p.errorMutex.Unlock()
+
+	return status
+}
+
+// close stops the keep alive, ends running calls and disconnects every client.
+func (p *clientPool) close() {
+	close(p.stop)
+	p.stopped.Wait()
+
+	for _, pc := range p.clients {
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		err := pc.client.Disconnect(ctx)
+		cancel()
+		if err != nil {
+			p.log.WithField("pool", pc.id).Debugf("failed to disconnect: %v", err)
+		}
+	}
+}
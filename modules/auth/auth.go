@@ -9,6 +9,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
 )
 
 const (
@@ -78,9 +80,10 @@ func (*Redirect) Error() string {
 type AuthHandler struct {
 	loginCallbackURL string
 	steamKey         string
+	log              *logging.Logger
 }
 
-func NewAuthHandler(rootURL string, loginPath string, steamKey string) *AuthHandler {
+func NewAuthHandler(rootURL string, loginPath string, steamKey string, log *logging.Logger) *AuthHandler {
 	callbackURL := rootURL
 	if strings.HasPrefix(loginPath, "/") {
 		loginPath = strings.TrimPrefix(loginPath, "/")
@@ -89,6 +92,7 @@ func NewAuthHandler(rootURL string, loginPath string, steamKey string) *AuthHand
 	return &AuthHandler{
 		loginCallbackURL: callbackURL,
 		steamKey:         steamKey,
+		log:              log.WithField("component", "auth"),
 	}
 }
 
@@ -98,6 +102,7 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) (*User, err
 	if !openidFound {
 		steamLogin, err := buildAuthURL(h.loginCallbackURL)
 		if err != nil {
+			h.log.Errorf("could not initialize steam login: %v", err)
 			return nil, errors.New("could not initialize steam login")
 		}
 
@@ -107,16 +112,19 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) (*User, err
 
 	steamId, err := validateOpenid(openid)
 	if err != nil {
+		h.log.Warnf("could not validate steam login: %v", err)
 		return nil, errors.New("could not validate steam login")
 	}
 
 	profile, err := fetchUserProfile(h.steamKey, steamId)
 	if err != nil {
+		h.log.WithField("steamId", steamId).Warnf("could not fetch user profile: %v", err)
 		return nil, errors.New("could not fetch user profile")
 	}
 
 	ownsGame, err := checkGameOwnership(h.steamKey, steamId, dragonsDogmaAppId)
 	if err != nil {
+		h.log.WithField("steamId", steamId).Warnf("could not fetch user games: %v", err)
 		return nil, errors.New("could not fetch user games")
 	}
 
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SteamAPIURL                     = "https://api.steampowered.com"
+	steamAuthenticateUserTicketPath = "/ISteamUserAuth/AuthenticateUserTicket/v1/"
+)
+
+type TokenDatabase interface {
+	GetGameToken(user string) ([]byte, error)
+	PutGameToken(user string, token []byte) error
+}
+
+var ticketClient = &http.Client{Timeout: 10 * time.Second}
+
+// AuthenticateUserTicket validates a game client's session ticket with the Steam Web API located at apiURL
+// and returns the 64 bit steam id of the ticket owner.
+func AuthenticateUserTicket(apiURL string, steamKey string, ticket []byte) (uint64, error) {
+	params := make(url.Values)
+	params.Set("key", steamKey)
+	params.Set("appid", strconv.Itoa(dragonsDogmaAppId))
+	params.Set("ticket", hex.EncodeToString(ticket))
+	ticketURL := strings.TrimSuffix(apiURL, "/") + steamAuthenticateUserTicketPath + "?" + params.Encode()
+
+	response, err := ticketClient.Get(ticketURL)
+	if err != nil {
+		return 0, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("ticket validation failed with status %d", response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	type Result struct {
+		Response struct {
+			Params *struct {
+				Result          string `json:"result"`
+				SteamId         string `json:"steamid"`
+				OwnerSteamId    string `json:"ownersteamid"`
+				VacBanned       bool   `json:"vacbanned"`
+				PublisherBanned bool   `json:"publisherbanned"`
+			} `json:"params"`
+			Error *struct {
+				ErrorCode int    `json:"errorcode"`
+				ErrorDesc string `json:"errordesc"`
+			} `json:"error"`
+		} `json:"response"`
+	}
+
+	var res Result
+	err = json.Unmarshal(body, &res)
+	if err != nil {
+		return 0, err
+	}
+
+	if res.Response.Error != nil {
+		return 0, fmt.Errorf("invalid ticket: %s (%d)", res.Response.Error.ErrorDesc, res.Response.Error.ErrorCode)
+	}
+
+	p := res.Response.Params
+	if p == nil || p.Result != "OK" {
+		return 0, errors.New("invalid ticket")
+	}
+
+	if p.PublisherBanned {
+		return 0, errors.New("user is banned")
+	}
+
+	steamId, err := strconv.ParseUint(p.SteamId, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid ticket: invalid steam id")
+	}
+
+	return steamId, nil
+}
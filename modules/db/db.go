@@ -17,6 +17,7 @@ type Database interface {
 	io.Closer
 	game.Database
 	auth.Database
+	auth.TokenDatabase
 }
 
 type boltDB struct {
@@ -36,6 +37,10 @@ func NewDatabase(path string) (Database, error) {
 		return nil, err
 	}
 
+	// Loading the dragon seeds the dragon gauges. A failure is already counted in the operation errors
+	// and only leaves the gauges empty until the next dragon write, so it does not keep the database closed.
+	database.GetOnlineUrDragon()
+
 	return database, nil
 }
 
@@ -49,7 +54,7 @@ func (db *boltDB) Close() error {
 }
 
 func (db *boltDB) init() error {
-	err := db.innerDB.Update(func(tx *bolt.Tx) error {
+	err := db.update("init", func(tx *bolt.Tx) error {
 		var err error
 		err = initDragonBucket(tx)
 		if err != nil {
@@ -66,6 +71,11 @@ func (db *boltDB) init() error {
 			return err
 		}
 
+		err = initTokenBucket(tx)
+		if err != nil {
+			return err
+		}
+
 		return nil
 	})
 
@@ -101,25 +111,17 @@ func initDragonBucket(tx *bolt.Tx) error {
 }
 
 func (db *boltDB) GetOnlineUrDragon() (dragon *game.OnlineUrDragon, err error) {
-	err = db.innerDB.View(func(tx *bolt.Tx) error {
+	err = db.view("get_dragon", func(tx *bolt.Tx) error {
 		b := tx.Bucket(dragonBucketName)
 		if b == nil {
 			return errors.New("database not initialized")
 		}
 
-		v := b.Get(dragonBucketKey)
-		if v == nil {
-			return errors.New("dragon not found")
-		}
-
-		var d game.OnlineUrDragon
-		err = json.Unmarshal(v, &d)
+		dragon, err = getOnlineUrDragonInternal(b)
 		if err != nil {
 			return err
 		}
 
-		dragon = &d
-
 		return nil
 	})
 
@@ -127,11 +129,13 @@ func (db *boltDB) GetOnlineUrDragon() (dragon *game.OnlineUrDragon, err error) {
 		return nil, fmt.Errorf("could not retrieve the online ur dragon: %v", err)
 	}
 
+	observeDragon(dragon)
+
 	return dragon, nil
 }
 
 func (db *boltDB) PutOnlineUrDragon(dragon *game.OnlineUrDragon) error {
-	err := db.innerDB.Update(func(tx *bolt.Tx) error {
+	err := db.update("put_dragon", func(tx *bolt.Tx) error {
 		b := tx.Bucket(dragonBucketName)
 		if b == nil {
 			return errors.New("database not initialized")
@@ -149,9 +153,62 @@ func (db *boltDB) PutOnlineUrDragon(dragon *game.OnlineUrDragon) error {
 		return fmt.Errorf("could not save the online ur dragon: %v", err)
 	}
 
+	observeDragon(dragon)
+
 	return nil
 }
 
+func (db *boltDB) UpdateOnlineUrDragon(update func(*game.OnlineUrDragon) error) error {
+	var dragon *game.OnlineUrDragon
+	err := db.update("update_dragon", func(tx *bolt.Tx) error {
+		b := tx.Bucket(dragonBucketName)
+		if b == nil {
+			return errors.New("database not initialized")
+		}
+
+		var err error
+		dragon, err = getOnlineUrDragonInternal(b)
+		if err != nil {
+			return err
+		}
+
+		err = update(dragon)
+		if err != nil {
+			return err
+		}
+
+		err = putOnlineUrDragonInternal(b, dragon)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not update the online ur dragon: %v", err)
+	}
+
+	observeDragon(dragon)
+
+	return nil
+}
+
+func getOnlineUrDragonInternal(b *bolt.Bucket) (*game.OnlineUrDragon, error) {
+	v := b.Get(dragonBucketKey)
+	if v == nil {
+		return nil, errors.New("dragon not found")
+	}
+
+	var d game.OnlineUrDragon
+	err := json.Unmarshal(v, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
 func putOnlineUrDragonInternal(b *bolt.Bucket, dragon *game.OnlineUrDragon) error {
 	v, err := json.Marshal(dragon)
 	if err != nil {
@@ -183,7 +240,7 @@ func initSessionBucket(tx *bolt.Tx) error {
 }
 
 func (db *boltDB) GetSession(ID string) (session *auth.Session, err error) {
-	err = db.innerDB.View(func(tx *bolt.Tx) error {
+	err = db.view("get_session", func(tx *bolt.Tx) error {
 		b := tx.Bucket(sessionBucketName)
 		if b == nil {
 			return errors.New("database not initialized")
@@ -213,7 +270,7 @@ func (db *boltDB) GetSession(ID string) (session *auth.Session, err error) {
 }
 
 func (db *boltDB) PutSession(session *auth.Session) error {
-	err := db.innerDB.Update(func(tx *bolt.Tx) error {
+	err := db.update("put_session", func(tx *bolt.Tx) error {
 		b := tx.Bucket(sessionBucketName)
 		if b == nil {
 			return errors.New("database not initialized")
@@ -240,7 +297,7 @@ func (db *boltDB) PutSession(session *auth.Session) error {
 }
 
 func (db *boltDB) DeleteSession(ID string) error {
-	err := db.innerDB.Update(func(tx *bolt.Tx) error {
+	err := db.update("delete_session", func(tx *bolt.Tx) error {
 		b := tx.Bucket(sessionBucketName)
 		if b == nil {
 			return errors.New("database not initialized")
@@ -261,6 +318,69 @@ func (db *boltDB) DeleteSession(ID string) error {
 	return nil
 }
 
+var (
+	tokenBucketName = []byte("token")
+)
+
+func initTokenBucket(tx *bolt.Tx) error {
+	b := tx.Bucket(tokenBucketName)
+	if b == nil {
+		_, err := tx.CreateBucket(tokenBucketName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *boltDB) GetGameToken(user string) (token []byte, err error) {
+	err = db.view("get_game_token", func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokenBucketName)
+		if b == nil {
+			return errors.New("database not initialized")
+		}
+
+		v := b.Get([]byte(user))
+		if v == nil {
+			return nil
+		}
+
+		token = make([]byte, len(v))
+		copy(token, v)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the game token: %v", err)
+	}
+
+	return token, nil
+}
+
+func (db *boltDB) PutGameToken(user string, token []byte) error {
+	err := db.update("put_game_token", func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokenBucketName)
+		if b == nil {
+			return errors.New("database not initialized")
+		}
+
+		err := b.Put([]byte(user), token)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not save the game token: %v", err)
+	}
+
+	return nil
+}
+
 var (
 	pawnRewardBucketName = []byte("pawnreward")
 )
@@ -278,7 +398,7 @@ func initPawnRewardBucket(tx *bolt.Tx) error {
 }
 
 func (db *boltDB) GetPawnRewards(userID uint64) (rewards *game.PawnRewards, err error) {
-	err = db.innerDB.View(func(tx *bolt.Tx) error {
+	err = db.view("get_pawn_rewards", func(tx *bolt.Tx) error {
 		b := tx.Bucket(pawnRewardBucketName)
 		if b == nil {
 			return errors.New("database not initialized")
@@ -308,7 +428,7 @@ func (db *boltDB) GetPawnRewards(userID uint64) (rewards *game.PawnRewards, err
 }
 
 func (db *boltDB) PutPawnRewards(rewards *game.PawnRewards) error {
-	err := db.innerDB.Update(func(tx *bolt.Tx) error {
+	err := db.update("put_pawn_rewards", func(tx *bolt.Tx) error {
 		b := tx.Bucket(pawnRewardBucketName)
 		if b == nil {
 			return errors.New("database not initialized")
@@ -1,8 +1,12 @@
 package db
 
 import (
+	"bytes"
 	"os"
+	"sync"
 	"testing"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
 )
 
 func cleanup(databasePath string, t *testing.T) {
@@ -66,3 +70,93 @@ func TestDatabase(t *testing.T) {
 		t.Errorf("failed to close database: %v", err)
 	}
 }
+
+func TestUpdateOnlineUrDragonConcurrent(t *testing.T) {
+	const databasePath = "test_concurrent.db"
+	cleanup(databasePath, t)
+	defer cleanup(databasePath, t)
+
+	database, err := NewDatabase(databasePath)
+	if err != nil {
+		t.Errorf("failed to create database: %v", err)
+		return
+	}
+	defer database.Close()
+
+	const adds = 500
+	props := []game.DragonProperty{
+		{Index: 31, Value2: 1},
+		{Index: 33, Value2: 2},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, adds)
+	for i := 0; i < adds; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- database.UpdateOnlineUrDragon(func(dragon *game.OnlineUrDragon) error {
+				_, err := dragon.AddProperties(props)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("failed to update dragon: %v", err)
+		}
+	}
+
+	dragon, err := database.GetOnlineUrDragon()
+	if err != nil {
+		t.Errorf("failed to get dragon data: %v", err)
+		return
+	}
+
+	if dragon.FightCount != adds {
+		t.Errorf("FightCount mismatch %d %d", dragon.FightCount, adds)
+	}
+
+	if dragon.KillCount != 2*adds {
+		t.Errorf("KillCount mismatch %d %d", dragon.KillCount, 2*adds)
+	}
+}
+
+func TestGameToken(t *testing.T) {
+	const databasePath = "test_token.db"
+	cleanup(databasePath, t)
+	defer cleanup(databasePath, t)
+
+	database, err := NewDatabase(databasePath)
+	if err != nil {
+		t.Errorf("failed to create database: %v", err)
+		return
+	}
+	defer database.Close()
+
+	token, err := database.GetGameToken("0110000100000001")
+	if err != nil {
+		t.Errorf("failed to get missing token: %v", err)
+	}
+
+	if token != nil {
+		t.Error("unexpected token for unknown user")
+	}
+
+	err = database.PutGameToken("0110000100000001", []byte{1, 2, 3})
+	if err != nil {
+		t.Errorf("failed to save token: %v", err)
+	}
+
+	token, err = database.GetGameToken("0110000100000001")
+	if err != nil {
+		t.Errorf("failed to get token: %v", err)
+	}
+
+	if !bytes.Equal(token, []byte{1, 2, 3}) {
+		t.Error("token mismatch")
+	}
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
+	"github.com/atvaark/dragons-dogma-server/modules/metrics"
+	"github.com/boltdb/bolt"
+)
+
+var (
+	operationDuration = metrics.NewHistogramVec(
+		"ddda_db_operation_duration_seconds",
+		"Time spent in a bolt transaction, by operation.",
+		metrics.DefaultBuckets,
+		"operation")
+	operationErrors = metrics.NewCounterVec(
+		"ddda_db_operation_errors_total",
+		"Failed bolt transactions, by operation.",
+		"operation")
+	dragonGeneration = metrics.NewGauge(
+		"ddda_dragon_generation",
+		"Generation of the online Ur Dragon.")
+	dragonHealth = metrics.NewGauge(
+		"ddda_dragon_health",
+		"Remaining health of all hearts of the online Ur Dragon.")
+)
+
+func (db *boltDB) view(operation string, fn func(tx *bolt.Tx) error) error {
+	return observeOperation(operation, func() error {
+		return db.innerDB.View(fn)
+	})
+}
+
+func (db *boltDB) update(operation string, fn func(tx *bolt.Tx) error) error {
+	return observeOperation(operation, func() error {
+		return db.innerDB.Update(fn)
+	})
+}
+
+func observeOperation(operation string, transaction func() error) error {
+	start := time.Now()
+	err := transaction()
+	operationDuration.With(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		operationErrors.With(operation).Inc()
+	}
+
+	return err
+}
+
+// observeDragon is called with every committed or loaded dragon so the gauges follow the scheduler and the clients.
+func observeDragon(dragon *game.OnlineUrDragon) {
+	var health float64
+	for _, heart := range dragon.Hearts {
+		health += float64(heart.Health)
+	}
+
+	dragonGeneration.Set(float64(dragon.Generation))
+	dragonHealth.Set(health)
+}
@@ -60,6 +60,7 @@ func (d *OnlineUrDragon) NextGeneration() *OnlineUrDragon {
 type DragonDatabase interface {
 	GetOnlineUrDragon() (*OnlineUrDragon, error)
 	PutOnlineUrDragon(*OnlineUrDragon) error
+	UpdateOnlineUrDragon(func(*OnlineUrDragon) error) error
 }
 
 type DragonProperty struct {
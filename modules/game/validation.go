@@ -0,0 +1,176 @@
+package game
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxKillTimeSkew is how far a client kill time may lie before the server time.
+// Older kill times would shorten or skip the grace period.
+const maxKillTimeSkew = 5 * time.Minute
+
+type PropertyVerdict int
+
+const (
+	PropertyAllowed PropertyVerdict = iota
+	PropertyClamped
+	PropertyRejected
+)
+
+func (v PropertyVerdict) String() string {
+	switch v {
+	case PropertyAllowed:
+		return "allowed"
+	case PropertyClamped:
+		return "clamped"
+	case PropertyRejected:
+		return "rejected"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(v))
+	}
+}
+
+type PropertyValidation struct {
+	Property DragonProperty
+	Verdict  PropertyVerdict
+	Reason   string
+}
+
+// ValidateProperties decides which client property writes may be applied to the dragon.
+// It returns the properties that may be passed to SetProperties and every write that was clamped or rejected.
+func (d *OnlineUrDragon) ValidateProperties(props []DragonProperty) ([]DragonProperty, []PropertyValidation) {
+	accepted := make([]DragonProperty, 0, len(props))
+	validations := make([]PropertyValidation, 0)
+	heartsDead := d.heartsDeadAfter(props)
+
+	for _, prop := range props {
+		validated, verdict, reason := d.validateProperty(prop, heartsDead)
+		if verdict != PropertyRejected {
+			accepted = append(accepted, validated)
+		}
+
+		if verdict != PropertyAllowed {
+			validations = append(validations, PropertyValidation{
+				Property: prop,
+				Verdict:  verdict,
+				Reason:   reason,
+			})
+		}
+	}
+
+	return accepted, validations
+}
+
+// heartsDeadAfter reports whether every heart has lost all of its health once the valid heart writes in props are applied.
+func (d *OnlineUrDragon) heartsDeadAfter(props []DragonProperty) bool {
+	const heartPropCount = UrDragonHeartCount / 2
+	const dragonHeartsHealthIndexStart = 1
+	const dragonHeartsHealthIndexEnd = dragonHeartsHealthIndexStart + heartPropCount
+
+	hearts := d.Hearts
+	for _, prop := range props {
+		if prop.Index < dragonHeartsHealthIndexStart || prop.Index >= dragonHeartsHealthIndexEnd {
+			continue
+		}
+
+		heartIndex := int(prop.Index-dragonHeartsHealthIndexStart) * 2
+		hearts[heartIndex].Health = minUint32(prop.Value1, hearts[heartIndex].Health)
+		hearts[heartIndex+1].Health = minUint32(prop.Value2, hearts[heartIndex+1].Health)
+	}
+
+	for _, h := range hearts {
+		if h.Health > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *OnlineUrDragon) validateProperty(prop DragonProperty, heartsDead bool) (DragonProperty, PropertyVerdict, string) {
+	const heartPropCount = UrDragonHeartCount / 2
+	const dragonHeartsHealthIndexStart = 1
+	const dragonHeartsHealthIndexEnd = dragonHeartsHealthIndexStart + heartPropCount
+	const dragonHeartsHealthMaxIndexStart = dragonHeartsHealthIndexEnd
+	const dragonHeartsHealthMaxIndexEnd = dragonHeartsHealthMaxIndexStart + heartPropCount
+	const userIdsIndexStart = 35
+	const userIdPropCount = UserIdCount * 2
+	const userIdsIndexEnd = userIdsIndexStart + userIdPropCount
+
+	switch {
+	case prop.Index == 0:
+		return prop, PropertyRejected, "generation is server-owned"
+	case prop.Index >= dragonHeartsHealthIndexStart && prop.Index < dragonHeartsHealthIndexEnd:
+		heartIndex := int(prop.Index-dragonHeartsHealthIndexStart) * 2
+		clamped := prop
+		clamped.Value1 = minUint32(prop.Value1, d.Hearts[heartIndex].Health)
+		clamped.Value2 = minUint32(prop.Value2, d.Hearts[heartIndex+1].Health)
+		if clamped != prop {
+			return clamped, PropertyClamped, "hearts may only lose health"
+		}
+
+		return prop, PropertyAllowed, ""
+	case prop.Index >= dragonHeartsHealthMaxIndexStart && prop.Index < dragonHeartsHealthMaxIndexEnd:
+		return prop, PropertyRejected, "max heart health is server-owned"
+	case prop.Index == 31:
+		if prop.Value2 != d.FightCount {
+			clamped := prop
+			clamped.Value2 = d.FightCount
+			return clamped, PropertyClamped, "fight count may only change through adds"
+		}
+
+		return prop, PropertyAllowed, ""
+	case prop.Index == 32:
+		if d.KillTime != nil {
+			return prop, PropertyRejected, "kill time is already set"
+		}
+
+		if prop.Value2 == 0 {
+			return prop, PropertyAllowed, ""
+		}
+
+		if !heartsDead {
+			return prop, PropertyRejected, "kill time requires every heart to be dead"
+		}
+
+		now := time.Now().UTC()
+		if prop.Value2 < uint32(now.Add(-maxKillTimeSkew).Unix()) ||
+			(d.SpawnTime != nil && prop.Value2 < uint32(d.SpawnTime.Unix())) {
+			return prop, PropertyRejected, "kill time may not be in the past"
+		}
+
+		if prop.Value2 > uint32(now.Unix()) {
+			clamped := prop
+			clamped.Value2 = uint32(now.Unix())
+			return clamped, PropertyClamped, "kill time may not be in the future"
+		}
+
+		return prop, PropertyAllowed, ""
+	case prop.Index == 33:
+		if prop.Value2 != d.KillCount {
+			clamped := prop
+			clamped.Value2 = d.KillCount
+			return clamped, PropertyClamped, "kill count may only change through adds"
+		}
+
+		return prop, PropertyAllowed, ""
+	case prop.Index >= userIdsIndexStart && prop.Index < userIdsIndexEnd:
+		return prop, PropertyRejected, "pawn user ids are server-owned"
+	case prop.Index == 41:
+		return prop, PropertyRejected, "defense is server-owned"
+	case prop.Index == 42:
+		return prop, PropertyRejected, "spawn time is server-owned"
+	case prop.Index >= UsedDragonProperties:
+		return prop, PropertyRejected, fmt.Sprintf("invalid property index %d", prop.Index)
+	default:
+		return prop, PropertyRejected, "unused property"
+	}
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,157 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidatePropertiesServerOwned(t *testing.T) {
+	dragon := (&OnlineUrDragon{}).NextGeneration()
+
+	serverOwned := []uint8{0, 16, 30, 35, 36, 40, 41, 42, 34, UsedDragonProperties, MaxDragonProperties}
+	for _, index := range serverOwned {
+		accepted, validations := dragon.ValidateProperties([]DragonProperty{{Index: index, Value1: 1, Value2: 1}})
+		if len(accepted) != 0 {
+			t.Errorf("property %d was accepted", index)
+		}
+
+		if len(validations) != 1 || validations[0].Verdict != PropertyRejected {
+			t.Errorf("property %d was not rejected", index)
+		}
+	}
+}
+
+func TestValidatePropertiesHearts(t *testing.T) {
+	dragon := (&OnlineUrDragon{}).NextGeneration()
+	dragon.Hearts[2].Health = 100
+
+	accepted, validations := dragon.ValidateProperties([]DragonProperty{
+		{Index: 1, Value1: 10, Value2: UrDragonHeartHealth + 1},
+		{Index: 2, Value1: 200, Value2: 50},
+	})
+
+	if len(accepted) != 2 {
+		t.Errorf("accepted property count mismatch %d %d", len(accepted), 2)
+		return
+	}
+
+	if accepted[0].Value1 != 10 || accepted[0].Value2 != UrDragonHeartHealth {
+		t.Errorf("heart health was not clamped: %v", accepted[0])
+	}
+
+	if accepted[1].Value1 != 100 || accepted[1].Value2 != 50 {
+		t.Errorf("heart health was not clamped: %v", accepted[1])
+	}
+
+	if len(validations) != 2 {
+		t.Errorf("validation count mismatch %d %d", len(validations), 2)
+		return
+	}
+
+	for _, v := range validations {
+		if v.Verdict != PropertyClamped {
+			t.Errorf("property %d verdict mismatch %v %v", v.Property.Index, v.Verdict, PropertyClamped)
+		}
+	}
+}
+
+func TestValidatePropertiesCounters(t *testing.T) {
+	dragon := (&OnlineUrDragon{}).NextGeneration()
+	dragon.FightCount = 10
+	dragon.KillCount = 5
+
+	accepted, validations := dragon.ValidateProperties([]DragonProperty{
+		{Index: 31, Value2: 9},
+		{Index: 31, Value2: 1000},
+		{Index: 33, Value2: 6},
+	})
+
+	if len(accepted) != 3 {
+		t.Errorf("accepted property count mismatch %d %d", len(accepted), 3)
+		return
+	}
+
+	if accepted[0].Value2 != 10 || accepted[1].Value2 != 10 {
+		t.Errorf("FightCount was not clamped: %d %d", accepted[0].Value2, accepted[1].Value2)
+	}
+
+	if accepted[2].Value2 != 5 {
+		t.Errorf("KillCount was not clamped: %d", accepted[2].Value2)
+	}
+
+	if len(validations) != 3 {
+		t.Errorf("unexpected validations %v", validations)
+	}
+}
+
+func TestValidatePropertiesKillTime(t *testing.T) {
+	dragon := (&OnlineUrDragon{}).NextGeneration()
+	for i := range dragon.Hearts {
+		dragon.Hearts[i].Health = 0
+	}
+
+	accepted, validations := dragon.ValidateProperties([]DragonProperty{{Index: 32, Value2: ^uint32(0)}})
+	if len(accepted) != 1 || len(validations) != 1 || validations[0].Verdict != PropertyClamped {
+		t.Errorf("future kill time was not clamped: %v", validations)
+	}
+
+	err := dragon.SetProperties(accepted)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	accepted, validations = dragon.ValidateProperties([]DragonProperty{{Index: 32, Value2: 1}})
+	if len(accepted) != 0 || len(validations) != 1 || validations[0].Verdict != PropertyRejected {
+		t.Errorf("kill time was overwritten: %v", validations)
+	}
+}
+
+func TestValidatePropertiesKillExploit(t *testing.T) {
+	dragon := (&OnlineUrDragon{}).NextGeneration()
+
+	accepted, validations := dragon.ValidateProperties([]DragonProperty{
+		{Index: 32, Value2: 1},
+		{Index: 33, Value2: GraceKillsMin},
+	})
+
+	if len(validations) != 2 || validations[0].Verdict != PropertyRejected || validations[1].Verdict != PropertyClamped {
+		t.Errorf("unexpected validations %v", validations)
+	}
+
+	err := dragon.SetProperties(accepted)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if next := dragon.Tick(); next.Generation != dragon.Generation {
+		t.Errorf("dragon advanced to generation %d while its hearts were alive", next.Generation)
+	}
+}
+
+func TestValidatePropertiesKillTimeWithHearts(t *testing.T) {
+	dragon := (&OnlineUrDragon{}).NextGeneration()
+
+	props := []DragonProperty{{Index: 32, Value2: uint32(time.Now().UTC().Unix())}}
+	for i := uint8(1); i <= UrDragonHeartCount/2; i++ {
+		props = append(props, DragonProperty{Index: i})
+	}
+
+	accepted, validations := dragon.ValidateProperties(props)
+	if len(accepted) != len(props) || len(validations) != 0 {
+		t.Errorf("kill time was not accepted with the killing blow: %v", validations)
+	}
+
+	props[0].Value2 = 1
+	accepted, validations = dragon.ValidateProperties(props)
+	if len(accepted) != len(props)-1 || len(validations) != 1 || validations[0].Verdict != PropertyRejected {
+		t.Errorf("past kill time was not rejected: %v", validations)
+	}
+
+	props[0].Value2 = uint32(time.Now().UTC().Add(-2 * maxKillTimeSkew).Unix())
+	accepted, validations = dragon.ValidateProperties(props)
+	if len(accepted) != len(props)-1 || len(validations) != 1 || validations[0].Verdict != PropertyRejected {
+		t.Errorf("kill time before the skew was not rejected: %v", validations)
+	}
+}
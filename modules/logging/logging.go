@@ -0,0 +1,220 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+func ParseLevel(level string) (Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	default:
+		return InfoLevel, fmt.Errorf("unknown log level %s", level)
+	}
+}
+
+type Format int
+
+const (
+	TextFormat Format = iota
+	JSONFormat
+)
+
+func ParseFormat(format string) (Format, error) {
+	switch strings.ToLower(format) {
+	case "text":
+		return TextFormat, nil
+	case "json":
+		return JSONFormat, nil
+	default:
+		return TextFormat, fmt.Errorf("unknown log format %s", format)
+	}
+}
+
+type Fields map[string]interface{}
+
+type output struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	level  Level
+	format Format
+}
+
+// Logger writes leveled messages with fields. Loggers derived with WithField share the output of their parent.
+// A nil Logger logs like Default.
+type Logger struct {
+	out    *output
+	fields Fields
+}
+
+func New(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{
+		out: &output{
+			writer: w,
+			level:  level,
+			format: format,
+		},
+	}
+}
+
+var defaultLogger = New(os.Stderr, InfoLevel, TextFormat)
+
+// Default logs info and above as text to stderr.
+func Default() *Logger {
+	return defaultLogger
+}
+
+func (l *Logger) orDefault() *Logger {
+	if l == nil {
+		return defaultLogger
+	}
+
+	return l
+}
+
+func (l *Logger) WithField(key string, value interface{}) *Logger {
+	return l.WithFields(Fields{key: value})
+}
+
+func (l *Logger) WithFields(fields Fields) *Logger {
+	l = l.orDefault()
+
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return &Logger{out: l.out, fields: merged}
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.orDefault().out.level
+}
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.logf(DebugLevel, format, v...)
+}
+
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.logf(InfoLevel, format, v...)
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.logf(WarnLevel, format, v...)
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.logf(ErrorLevel, format, v...)
+}
+
+func (l *Logger) logf(level Level, format string, v ...interface{}) {
+	l = l.orDefault()
+	if !l.Enabled(level) {
+		return
+	}
+
+	now := time.Now().UTC()
+	message := strings.TrimSuffix(fmt.Sprintf(format, v...), "\n")
+
+	var line []byte
+	if l.out.format == JSONFormat {
+		line = l.formatJSON(now, level, message)
+	} else {
+		line = l.formatText(now, level, message)
+	}
+
+	l.out.mutex.Lock()
+	defer l.out.mutex.Unlock()
+	l.out.writer.Write(line)
+}
+
+func (l *Logger) sortedKeys() []string {
+	keys := make([]string, 0, len(l.fields))
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (l *Logger) formatText(now time.Time, level Level, message string) []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "%s %-5s %s", now.Format(time.RFC3339), strings.ToUpper(level.String()), message)
+
+	for _, k := range l.sortedKeys() {
+		value := fmt.Sprint(l.fields[k])
+		if strings.ContainsAny(value, " \"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(&buffer, " %s=%s", k, value)
+	}
+
+	buffer.WriteByte('\n')
+	return buffer.Bytes()
+}
+
+func (l *Logger) formatJSON(now time.Time, level Level, message string) []byte {
+	entry := make(map[string]interface{}, len(l.fields)+3)
+	for k, v := range l.fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		entry[k] = v
+	}
+	entry["time"] = now.Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = message
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{
+			"time":  entry["time"],
+			"level": entry["level"],
+			"msg":   message,
+			"error": fmt.Sprintf("failed to encode log fields: %v", err),
+		})
+	}
+
+	return append(line, '\n')
+}
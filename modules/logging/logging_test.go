@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLevelFilter(t *testing.T) {
+	var buffer bytes.Buffer
+	log := New(&buffer, WarnLevel, TextFormat)
+
+	log.Debugf("debug")
+	log.Infof("info")
+	log.Warnf("warn")
+	log.Errorf("error")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "WARN  warn") || !strings.Contains(lines[1], "ERROR error") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestTextFields(t *testing.T) {
+	var buffer bytes.Buffer
+	log := New(&buffer, DebugLevel, TextFormat).WithField("conn", 1).WithFields(Fields{"user": "a b", "seq": 7})
+
+	log.Infof("connected\n")
+
+	line := buffer.String()
+	if !strings.HasSuffix(line, "INFO  connected conn=1 seq=7 user=\"a b\"\n") {
+		t.Errorf("unexpected output %q", line)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var buffer bytes.Buffer
+	parent := New(&buffer, InfoLevel, JSONFormat)
+	parent.WithField("conn", 1).Infof("request %d", 2)
+	parent.Infof("no fields")
+
+	decoder := json.NewDecoder(&buffer)
+
+	var entry map[string]interface{}
+	err := decoder.Decode(&entry)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if entry["msg"] != "request 2" || entry["level"] != "info" || entry["conn"] != float64(1) {
+		t.Errorf("unexpected entry %v", entry)
+	}
+
+	entry = nil
+	err = decoder.Decode(&entry)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, ok := entry["conn"]; ok {
+		t.Errorf("child fields leaked into the parent %v", entry)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("WARNING")
+	if err != nil || level != WarnLevel {
+		t.Errorf("unexpected level %v %v", level, err)
+	}
+
+	_, err = ParseLevel("verbose")
+	if err == nil {
+		t.Error("unknown level was accepted")
+	}
+}
@@ -0,0 +1,364 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are latency buckets in seconds that span fast in-memory requests up to slow bolt commits.
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Registry collects metric families and writes them in the Prometheus text exposition format.
+type Registry struct {
+	mutex    sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// DefaultRegistry holds the metrics that the packages of this server declare at package level.
+var DefaultRegistry = NewRegistry()
+
+type metric interface {
+	write(w *bufio.Writer, name string, labels string)
+}
+
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	newMetric  func() metric
+	mutex      sync.Mutex
+	metrics    map[string]*labeledMetric
+}
+
+type labeledMetric struct {
+	labels string
+	metric metric
+}
+
+func (r *Registry) register(name, help, kind string, labelNames []string, newMetric func() metric) *family {
+	if !metricNamePattern.MatchString(name) {
+		panic(fmt.Sprintf("invalid metric name %s", name))
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.families[name]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		newMetric:  newMetric,
+		metrics:    make(map[string]*labeledMetric),
+	}
+	r.families[name] = f
+
+	return f
+}
+
+func (f *family) with(labelValues []string) metric {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d labels, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	labels := formatLabels(f.labelNames, labelValues)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	m, ok := f.metrics[labels]
+	if !ok {
+		m = &labeledMetric{labels: labels, metric: f.newMetric()}
+		f.metrics[labels] = m
+	}
+
+	return m.metric
+}
+
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%q", name, values[i])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mutex.Lock()
+	metrics := make([]*labeledMetric, 0, len(f.metrics))
+	for _, m := range f.metrics {
+		metrics = append(metrics, m)
+	}
+	f.mutex.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].labels < metrics[j].labels
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, strings.Replace(f.help, "\n", " ", -1))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+	for _, m := range metrics {
+		m.metric.write(w, f.name, m.labels)
+	}
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	buffered := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(buffered)
+	}
+
+	return buffered.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+func writeSample(w *bufio.Writer, name string, labels string, value float64) {
+	if len(labels) > 0 {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(value))
+	} else {
+		fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+	}
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&f.bits, old, next) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(value float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(value))
+}
+
+func (f *atomicFloat) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// Counter only goes up.
+type Counter struct {
+	value atomicFloat
+}
+
+func (c *Counter) Inc() {
+	c.value.add(1)
+}
+
+// Add panics on negative values because counters must not decrease.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("counter cannot decrease")
+	}
+	c.value.add(delta)
+}
+
+func (c *Counter) Value() float64 {
+	return c.value.get()
+}
+
+func (c *Counter) write(w *bufio.Writer, name string, labels string) {
+	writeSample(w, name, labels, c.value.get())
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	value atomicFloat
+}
+
+func (g *Gauge) Set(value float64) {
+	g.value.set(value)
+}
+
+func (g *Gauge) Inc() {
+	g.value.add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.add(-1)
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.value.add(delta)
+}
+
+func (g *Gauge) Value() float64 {
+	return g.value.get()
+}
+
+func (g *Gauge) write(w *bufio.Writer, name string, labels string) {
+	writeSample(w, name, labels, g.value.get())
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	mutex   sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *Histogram) Count() uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.count
+}
+
+func (h *Histogram) write(w *bufio.Writer, name string, labels string) {
+	h.mutex.Lock()
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	count, sum := h.count, h.sum
+	h.mutex.Unlock()
+
+	separator := ""
+	if len(labels) > 0 {
+		separator = ","
+	}
+
+	for i, bound := range h.buckets {
+		writeSample(w, name+"_bucket", fmt.Sprintf("%s%sle=%q", labels, separator, formatFloat(bound)), float64(counts[i]))
+	}
+	writeSample(w, name+"_bucket", fmt.Sprintf("%s%sle=\"+Inf\"", labels, separator), float64(count))
+	writeSample(w, name+"_sum", labels, sum)
+	writeSample(w, name+"_count", labels, float64(count))
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.register(name, help, "counter", nil, func() metric { return &Counter{} }).with(nil).(*Counter)
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.register(name, help, "gauge", nil, func() metric { return &Gauge{} }).with(nil).(*Gauge)
+}
+
+// CounterVec is a family of counters that are told apart by their label values.
+type CounterVec struct {
+	family *family
+}
+
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{r.register(name, help, "counter", labelNames, func() metric { return &Counter{} })}
+}
+
+// With returns the counter of the label values, which are given in the order of the label names.
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.family.with(labelValues).(*Counter)
+}
+
+// HistogramVec is a family of histograms with the same buckets that are told apart by their label values.
+type HistogramVec struct {
+	family *family
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return &HistogramVec{r.register(name, help, "histogram", labelNames, func() metric { return newHistogram(sorted) })}
+}
+
+// With returns the histogram of the label values, which are given in the order of the label names.
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.family.with(labelValues).(*Histogram)
+}
+
+func NewCounter(name, help string) *Counter {
+	return DefaultRegistry.NewCounter(name, help)
+}
+
+func NewGauge(name, help string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help)
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return DefaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return DefaultRegistry.NewHistogramVec(name, help, buckets, labelNames...)
+}
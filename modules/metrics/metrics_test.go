@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests.", "packet", "error_id")
+	connections := r.NewGauge("connections", "Connections.")
+	duration := r.NewHistogramVec("duration_seconds", "Durations.", []float64{1, 0.1}, "packet")
+
+	requests.With("b", "0x00").Inc()
+	requests.With("a", "0x06").Add(2)
+	connections.Inc()
+	connections.Inc()
+	connections.Dec()
+	duration.With("a").Observe(0.05)
+	duration.With("a").Observe(0.5)
+	duration.With("a").Observe(5)
+
+	var buffer bytes.Buffer
+	err := r.WriteText(&buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := `# HELP connections Connections.
+# TYPE connections gauge
+connections 1
+# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{packet="a",le="0.1"} 1
+duration_seconds_bucket{packet="a",le="1"} 2
+duration_seconds_bucket{packet="a",le="+Inf"} 3
+duration_seconds_sum{packet="a"} 5.55
+duration_seconds_count{packet="a"} 3
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{packet="a",error_id="0x06"} 2
+requests_total{packet="b",error_id="0x00"} 1
+`
+	if buffer.String() != expected {
+		t.Errorf("unexpected output\n%s", buffer.String())
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("hits_total", "Hits.").Inc()
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", recorder.Header().Get("Content-Type"))
+	}
+
+	if !strings.Contains(recorder.Body.String(), "hits_total 1\n") {
+		t.Errorf("unexpected body %s", recorder.Body.String())
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("connections", "Connections.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate metric was registered")
+		}
+	}()
+
+	r.NewCounter("connections", "Connections.")
+}
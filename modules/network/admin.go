@@ -0,0 +1,150 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+var ErrConnectionNotFound = errors.New("connection not found")
+
+// ConnectionInfo describes a connected client for administrators.
+type ConnectionInfo struct {
+	ID             int64     `json:"id"`
+	User           string    `json:"user,omitempty"`
+	RemoteAddr     string    `json:"remoteAddress,omitempty"`
+	ConnectedAt    time.Time `json:"connectedAt"`
+	LastPacketAt   time.Time `json:"lastPacketAt,omitempty"`
+	LastPacket     string    `json:"lastPacket,omitempty"`
+	LastPacketType uint16    `json:"lastPacketNameId,omitempty"`
+}
+
+// Info returns the metadata of the connection. LastPacket is the last packet received from the remote side.
+func (conn *ClientConn) Info() ConnectionInfo {
+	conn.sequenceMutex.Lock()
+	lastPacketTime, lastPacketType := conn.lastPacketTime, conn.lastPacketType
+	conn.sequenceMutex.Unlock()
+
+	info := ConnectionInfo{
+		ID:          conn.ID,
+		User:        conn.User,
+		RemoteAddr:  conn.remoteAddr(),
+		ConnectedAt: conn.connectedAt,
+	}
+
+	if !lastPacketTime.IsZero() {
+		info.LastPacketAt = lastPacketTime
+		info.LastPacket = lastPacketType.String()
+		info.LastPacketType = uint16(lastPacketType.NameID)
+	}
+
+	return info
+}
+
+func (conn *ClientConn) remoteAddr() string {
+	if netConn, ok := conn.ReadWriteCloser.(net.Conn); ok {
+		return netConn.RemoteAddr().String()
+	}
+
+	return ""
+}
+
+// addClient fails once the server is shutting down so that Shutdown does not miss late clients.
+func (s *Server) addClient(client *ClientConn) bool {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if s.isShuttingDown() {
+		return false
+	}
+
+	s.clients[client.ID] = client
+	return true
+}
+
+func (s *Server) removeClient(client *ClientConn) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	if s.clients[client.ID] == client {
+		delete(s.clients, client.ID)
+	}
+}
+
+// client ignores clients that were closed but whose goroutine has not removed them yet.
+func (s *Server) client(connID int64) (*ClientConn, bool) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	client, ok := s.clients[connID]
+	if !ok || client.isClosed() {
+		return nil, false
+	}
+	return client, true
+}
+
+func (s *Server) connectedClients() []*ClientConn {
+	s.clientsMutex.Lock()
+	clients := make([]*ClientConn, 0, len(s.clients))
+	for _, client := range s.clients {
+		if !client.isClosed() {
+			clients = append(clients, client)
+		}
+	}
+	s.clientsMutex.Unlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ID < clients[j].ID
+	})
+
+	return clients
+}
+
+// Connections lists the authenticated clients ordered by connection id.
+func (s *Server) Connections() []ConnectionInfo {
+	clients := s.connectedClients()
+	infos := make([]ConnectionInfo, len(clients))
+	for i, client := range clients {
+		infos[i] = client.Info()
+	}
+
+	return infos
+}
+
+// Kick shows the message to the client and closes its connection.
+func (s *Server) Kick(connID int64, message string) error {
+	client, ok := s.client(connID)
+	if !ok {
+		return ErrConnectionNotFound
+	}
+
+	err := disconnectAndClose(client, message)
+	if err != nil {
+		return err
+	}
+
+	client.Logger().Infof("kicked: %s", message)
+
+	return nil
+}
+
+// Broadcast sends the notification to every authenticated client and returns how many clients received it.
+// Clients that fail to receive it are logged and skipped.
+func (s *Server) Broadcast(notification Packet) (int, error) {
+	packetType := GetPacketType(notification)
+	if packetType.TypeID != notificationID {
+		return 0, fmt.Errorf("%v is not a notification", &packetType)
+	}
+
+	sent := 0
+	for _, client := range s.connectedClients() {
+		err := client.Send(notification)
+		if err != nil {
+			client.Logger().Warnf("failed to broadcast %v: %v", &packetType, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
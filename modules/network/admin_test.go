@@ -0,0 +1,74 @@
+package network
+
+import (
+	"testing"
+)
+
+func TestKickAndBroadcast(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&TusCommonAreaAcquisitionRequest{PropertyIndices: []byte{1}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = conn.Recv()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	connections := s.Connections()
+	if len(connections) != 1 || connections[0].ID != 1 || connections[0].LastPacket != "tusCommonAreaAcquisition request" {
+		t.Errorf("unexpected connections %+v", connections)
+		return
+	}
+
+	received := make(chan Packet, 2)
+	go func() {
+		for {
+			packet, err := conn.Recv()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- packet
+		}
+	}()
+
+	sent, err := s.Broadcast(&ReconnectionNotification{Host: "localhost", Port: 12501})
+	if err != nil || sent != 1 {
+		t.Errorf("unexpected broadcast result %d %v", sent, err)
+	}
+
+	_, err = s.Broadcast(&OnlineCheckRequest{})
+	if err == nil {
+		t.Error("broadcast a request")
+	}
+
+	err = s.Kick(1, "maintenance")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if packet, ok := (<-received).(*ReconnectionNotification); !ok || packet.Host != "localhost" {
+		t.Errorf("unexpected broadcast %v", packet)
+	}
+
+	if packet, ok := (<-received).(*DisconnectionNotification); !ok || packet.Notification != "maintenance" {
+		t.Errorf("unexpected kick %v", packet)
+	}
+
+	if _, open := <-received; open {
+		t.Error("kicked connection was not closed")
+	}
+
+	err = s.Kick(1, "")
+	if err != ErrConnectionNotFound {
+		t.Errorf("unexpected error %v", err)
+	}
+}
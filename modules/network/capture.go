@@ -0,0 +1,254 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Capture files start with the magic and the format version followed by the records.
+// All numbers are big endian like the protocol itself.
+//
+// record:
+//
+//	int64        timestamp in unix nanoseconds
+//	uint8        direction
+//	int64        connection id
+//	PacketHeader 8 bytes as sent on the wire
+//	[]byte       payload of PacketHeader.Length bytes
+const (
+	captureMagic         = "DDCP"
+	captureVersion       = uint16(1)
+	captureHeaderLength  = len(captureMagic) + 2
+	captureFileMode      = 0644
+	captureFileOpenFlags = os.O_RDWR | os.O_CREATE | os.O_APPEND
+)
+
+type CaptureDirection uint8
+
+const (
+	ClientToServer CaptureDirection = 0x01
+	ServerToClient CaptureDirection = 0x02
+)
+
+func (d CaptureDirection) String() string {
+	switch d {
+	case ClientToServer:
+		return "client->server"
+	case ServerToClient:
+		return "server->client"
+	default:
+		return fmt.Sprintf("unknown(%x)", uint8(d))
+	}
+}
+
+type CaptureRecord struct {
+	Time      time.Time
+	Direction CaptureDirection
+	ConnID    int64
+	Header    PacketHeader
+	Payload   []byte
+}
+
+// Packet decodes the recorded packet.
+func (r *CaptureRecord) Packet() (Packet, error) {
+	return DecodePacket(r.Header, r.Payload)
+}
+
+type recordHeader struct {
+	Timestamp int64
+	Direction CaptureDirection
+	ConnID    int64
+	Header    PacketHeader
+}
+
+type CaptureWriter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	closer io.Closer
+}
+
+// NewCaptureWriter writes the capture file header to w.
+func NewCaptureWriter(w io.Writer) (*CaptureWriter, error) {
+	err := writeCaptureHeader(w)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CaptureWriter{writer: w}, nil
+}
+
+// OpenCaptureFile appends to an existing capture file or creates a new one.
+func OpenCaptureFile(path string) (*CaptureWriter, error) {
+	file, err := os.OpenFile(path, captureFileOpenFlags, captureFileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.Size() == 0 {
+		err = writeCaptureHeader(file)
+	} else {
+		err = readCaptureHeader(io.NewSectionReader(file, 0, int64(captureHeaderLength)))
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("capture file %s: %v", path, err)
+	}
+
+	return &CaptureWriter{writer: file, closer: file}, nil
+}
+
+func writeCaptureHeader(w io.Writer) error {
+	var header [captureHeaderLength]byte
+	copy(header[:], captureMagic)
+	binary.BigEndian.PutUint16(header[len(captureMagic):], captureVersion)
+
+	_, err := w.Write(header[:])
+	return err
+}
+
+func readCaptureHeader(r io.Reader) error {
+	var header [captureHeaderLength]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return err
+	}
+
+	if string(header[:len(captureMagic)]) != captureMagic {
+		return errors.New("not a capture file")
+	}
+
+	version := binary.BigEndian.Uint16(header[len(captureMagic):])
+	if version != captureVersion {
+		return fmt.Errorf("unsupported capture version %d", version)
+	}
+
+	return nil
+}
+
+// WriteRecord appends one record. It is safe to share a writer between connections.
+func (w *CaptureWriter) WriteRecord(record *CaptureRecord) error {
+	if int(record.Header.Length) != len(record.Payload) {
+		return NewPayloadError(len(record.Payload), int(record.Header.Length))
+	}
+
+	var buffer bytes.Buffer
+	err := binary.Write(&buffer, binary.BigEndian, recordHeader{
+		Timestamp: record.Time.UnixNano(),
+		Direction: record.Direction,
+		ConnID:    record.ConnID,
+		Header:    record.Header,
+	})
+	if err != nil {
+		return err
+	}
+
+	buffer.Write(record.Payload)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	_, err = w.writer.Write(buffer.Bytes())
+	return err
+}
+
+func (w *CaptureWriter) Close() error {
+	if w.closer == nil {
+		return nil
+	}
+
+	return w.closer.Close()
+}
+
+type CaptureReader struct {
+	reader io.Reader
+}
+
+// NewCaptureReader validates the capture file header of r.
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	err := readCaptureHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CaptureReader{reader: r}, nil
+}
+
+// ReadRecord returns the next record or io.EOF at the end of the capture.
+func (r *CaptureReader) ReadRecord() (*CaptureRecord, error) {
+	var header recordHeader
+	err := binary.Read(r.reader, binary.BigEndian, &header)
+	if err == io.ErrUnexpectedEOF {
+		return nil, errors.New("truncated capture record")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, int(header.Header.Length))
+	_, err = io.ReadFull(r.reader, payload)
+	if err != nil {
+		return nil, fmt.Errorf("truncated capture payload: %v", err)
+	}
+
+	return &CaptureRecord{
+		Time:      time.Unix(0, header.Timestamp),
+		Direction: header.Direction,
+		ConnID:    header.ConnID,
+		Header:    header.Header,
+		Payload:   payload,
+	}, nil
+}
+
+// ReadPacket returns the next record together with its decoded packet.
+func (r *CaptureReader) ReadPacket() (*CaptureRecord, Packet, error) {
+	record, err := r.ReadRecord()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packet, err := record.Packet()
+	if err != nil {
+		return record, nil, err
+	}
+
+	return record, packet, nil
+}
+
+// SetCapture records every packet sent and received on the connection to w.
+func (conn *ClientConn) SetCapture(w *CaptureWriter) {
+	conn.capture = w
+}
+
+func (conn *ClientConn) record(sent bool, header PacketHeader, payload []byte) {
+	if conn.capture == nil {
+		return
+	}
+
+	direction := ClientToServer
+	if sent == conn.ToRemoteClient {
+		direction = ServerToClient
+	}
+
+	err := conn.capture.WriteRecord(&CaptureRecord{
+		Time:      time.Now(),
+		Direction: direction,
+		ConnID:    conn.ID,
+		Header:    header,
+		Payload:   payload,
+	})
+	if err != nil {
+		conn.packetLogger(&header).Warnf("failed to capture %v: %v", &header, err)
+	}
+}
@@ -0,0 +1,162 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	capture, err := NewCaptureWriter(&buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	server := NewClientConn(serverSide, 7, true)
+	server.SetCapture(capture)
+	client := NewClientConn(clientSide, 0, false)
+
+	request := &TusCommonAreaAcquisitionRequest{PropertyIndices: []byte{1, 2, 3}}
+	response := &TusCommonAreaAcquisitionResponse{PropertyPacket{Properties: []Property{{Index: 1, Value1: 2, Value2: 3}}}}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := server.Recv()
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		errs <- server.Send(response)
+	}()
+
+	err = client.Send(request)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = client.Recv()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = <-errs
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	reader, err := NewCaptureReader(&buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []struct {
+		direction CaptureDirection
+		packet    Packet
+	}{
+		{ClientToServer, request},
+		{ServerToClient, response},
+	}
+
+	for _, e := range expected {
+		record, packet, err := reader.ReadPacket()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if record.Direction != e.direction || record.ConnID != 7 {
+			t.Errorf("unexpected record %v %d", record.Direction, record.ConnID)
+		}
+
+		expectedPayload, _ := e.packet.Payload()
+		actualPayload, _ := packet.Payload()
+		if reflect.TypeOf(packet) != reflect.TypeOf(e.packet) || !bytes.Equal(expectedPayload, actualPayload) {
+			t.Errorf("packet mismatch %T %T", packet, e.packet)
+		}
+	}
+
+	_, err = reader.ReadRecord()
+	if err != io.EOF {
+		t.Errorf("expected the end of the capture, got %v", err)
+	}
+}
+
+func TestOpenCaptureFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "session.cap")
+	record := &CaptureRecord{Direction: ClientToServer, Header: PacketHeader{PacketType: GetPacketType(&OnlineCheckRequest{})}}
+
+	for i := 0; i < 2; i++ {
+		capture, err := OpenCaptureFile(path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		err = capture.WriteRecord(record)
+		if err != nil {
+			t.Error(err)
+		}
+
+		capture.Close()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer file.Close()
+
+	reader, err := NewCaptureReader(file)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		_, packet, err := reader.ReadPacket()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if _, ok := packet.(*OnlineCheckRequest); !ok {
+			t.Errorf("unexpected packet %T", packet)
+		}
+	}
+
+	invalidPath := filepath.Join(dir, "invalid.cap")
+	err = ioutil.WriteFile(invalidPath, []byte("not a capture"), 0644)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = OpenCaptureFile(invalidPath)
+	if err == nil {
+		t.Error("appended to a file that is not a capture")
+	}
+}
@@ -0,0 +1,53 @@
+package network
+
+import (
+	"fmt"
+)
+
+type chunkAssembler struct {
+	data           []byte
+	received       []bool
+	receivedLength int
+	maxChunkLength int
+}
+
+func newChunkAssembler(dataLength int, maxChunkLength int) *chunkAssembler {
+	return &chunkAssembler{
+		data:           make([]byte, dataLength),
+		received:       make([]bool, dataLength),
+		maxChunkLength: maxChunkLength,
+	}
+}
+
+func (a *chunkAssembler) Write(chunkOffset int, chunkData []byte) error {
+	chunkLength := len(chunkData)
+	if chunkLength > a.maxChunkLength {
+		return fmt.Errorf("chunk at offset %d exceeds the chunk length: %d > %d", chunkOffset, chunkLength, a.maxChunkLength)
+	}
+
+	if chunkOffset < 0 || chunkOffset+chunkLength > len(a.data) {
+		return fmt.Errorf("chunk at offset %d with length %d is out of range", chunkOffset, chunkLength)
+	}
+
+	for i := chunkOffset; i < chunkOffset+chunkLength; i++ {
+		if a.received[i] {
+			return fmt.Errorf("chunk at offset %d overlaps at offset %d", chunkOffset, i)
+		}
+	}
+
+	copy(a.data[chunkOffset:chunkOffset+chunkLength], chunkData)
+	for i := chunkOffset; i < chunkOffset+chunkLength; i++ {
+		a.received[i] = true
+	}
+	a.receivedLength += chunkLength
+
+	return nil
+}
+
+func (a *chunkAssembler) Complete() bool {
+	return a.receivedLength == len(a.data)
+}
+
+func (a *chunkAssembler) Bytes() []byte {
+	return a.data
+}
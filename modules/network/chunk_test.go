@@ -0,0 +1,61 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChunkAssembler(t *testing.T) {
+	a := newChunkAssembler(10, 4)
+
+	err := a.Write(4, []byte{4, 5, 6, 7})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = a.Write(0, []byte{0, 1, 2, 3})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if a.Complete() {
+		t.Error("incomplete data reported as complete")
+	}
+
+	err = a.Write(8, []byte{8, 9})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !a.Complete() {
+		t.Error("complete data reported as incomplete")
+	}
+
+	if !bytes.Equal(a.Bytes(), []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Error("data mismatch")
+	}
+}
+
+func TestChunkAssemblerInvalidChunks(t *testing.T) {
+	a := newChunkAssembler(10, 4)
+
+	err := a.Write(0, []byte{0, 1, 2, 3, 4})
+	if err == nil {
+		t.Error("oversized chunk was accepted")
+	}
+
+	err = a.Write(8, []byte{8, 9, 10})
+	if err == nil {
+		t.Error("out of range chunk was accepted")
+	}
+
+	err = a.Write(2, []byte{2, 3})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = a.Write(0, []byte{0, 1, 2})
+	if err == nil {
+		t.Error("overlapping chunk was accepted")
+	}
+}
@@ -1,77 +1,154 @@
 package network
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
+	"sync"
+	"time"
 
 	"github.com/atvaark/dragons-dogma-server/modules/game"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
 )
 
 type Client struct {
-	cfg  ClientConfig
-	conn *ClientConn
+	cfg     ClientConfig
+	mutex   sync.Mutex
+	conn    *ClientConn
+	capture *CaptureWriter
+	state   ClientState
 }
 
 type ClientConfig struct {
-	Host      string
-	Port      int
-	User      string
-	UserToken []byte
+	Host        string
+	Port        int
+	User        string
+	UserToken   []byte
+	CaptureFile string
+	Logger      *logging.Logger
+	// Dial replaces the TLS dialer, for example to reach an in-process server.
+	Dial func(ctx context.Context, address string) (net.Conn, error)
+
+	// Resilient reconnects with backoff whenever a call finds the connection closed,
+	// follows redirects of the server and retries idempotent calls that failed because the connection broke.
+	Resilient bool
+	Backoff   ReconnectBackoff
+	// OnStateChange is called with the mutex of the client held and must not call the client.
+	OnStateChange func(state ClientState, err error)
 }
 
+var ErrTokenRejected = errors.New("authentication failed. the server rejected the user token.")
+
+// NewClient creates a client that can be shared by several goroutines. Its calls are serialized
+// because the protocol allows only one exchange at a time.
 func NewClient(cfg ClientConfig) *Client {
 	return &Client{
 		cfg: cfg,
 	}
 }
 
-func (c *Client) Connect() error {
+// Connect dials and authenticates within the deadline of the context.
+// A resilient client keeps trying with backoff.
+func (c *Client) Connect(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if c.conn != nil {
 		return nil
 	}
 
-	conf := tls.Config{}
+	if c.cfg.Resilient {
+		return c.reconnect(ctx)
+	}
 
-	printf("connecting\n")
-	tlsConn, err := tls.Dial("tcp", fmt.Sprintf("%s:%d", c.cfg.Host, c.cfg.Port), &conf)
-	if err != nil {
-		return err
+	return c.connect(ctx)
+}
+
+// connect dials and authenticates once. The caller holds the mutex.
+func (c *Client) connect(ctx context.Context) error {
+	log := c.cfg.Logger.WithFields(logging.Fields{"host": c.cfg.Host, "port": c.cfg.Port})
+	log.Debugf("connecting")
+	c.setState(ClientConnecting, nil)
+
+	dial := c.cfg.Dial
+	if dial == nil {
+		dial = dialTLS
 	}
 
-	err = tlsConn.Handshake()
+	netConn, err := dial(ctx, fmt.Sprintf("%s:%d", c.cfg.Host, c.cfg.Port))
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		c.setState(ClientDisconnected, err)
 		return err
 	}
 
-	c.conn = NewClientConn(tlsConn, 0, false)
+	c.conn = NewClientConn(netConn, 0, false)
+	c.conn.EnableKeepAlive(0, 0)
+
+	if len(c.cfg.CaptureFile) > 0 && c.capture == nil {
+		c.capture, err = OpenCaptureFile(c.cfg.CaptureFile)
+		if err != nil {
+			c.close(err)
+			return err
+		}
+	}
+	c.conn.SetCapture(c.capture)
+	c.conn.SetLogger(log)
 
-	printf("authenticating\n")
+	log.Debugf("authenticating")
 
-	err = c.authenticate()
+	err = c.exchange(ctx, c.authenticate)
 	if err != nil {
+		c.close(err)
 		return err
 	}
 
-	printf("connected\n")
+	c.conn.Logger().Debugf("connected")
+	c.setState(ClientConnected, nil)
 
 	return nil
 }
 
-func (c *Client) Disconnect() error {
+func dialTLS(ctx context.Context, address string) (net.Conn, error) {
+	dialer := tls.Dialer{Config: &tls.Config{}}
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// Disconnect says goodbye to the server and closes the connection, even if the server does not answer.
+func (c *Client) Disconnect(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if c.conn == nil {
 		return nil
 	}
 
-	var err error
-	var response Packet
+	log := c.conn.Logger()
+	err := c.exchange(ctx, c.disconnect)
+	closeErr := c.close(nil)
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	log.Debugf("disconnected")
 
-	err = c.send(&DisconnectionRequest{BooleanPacket{Value: true}})
+	return nil
+}
+
+func (c *Client) disconnect() error {
+	err := c.send(&DisconnectionRequest{BooleanPacket{Value: true}})
 	if err != nil {
 		return err
 	}
 
-	response, err = c.recv()
+	response, err := c.recv()
 	if err != nil {
 		return err
 	}
@@ -80,22 +157,113 @@ func (c *Client) Disconnect() error {
 		return NewPacketTypeError(disconnectionResponse, response)
 	}
 
-	err = c.conn.Close()
-	if err != nil {
-		return err
+	return nil
+}
+
+// close closes the connection and the capture file. The cause is passed on to the state callback.
+// The caller holds the mutex.
+func (c *Client) close(cause error) error {
+	var err error
+	if c.conn != nil {
+		err = c.conn.Close()
+		c.conn = nil
+		c.setState(ClientDisconnected, cause)
 	}
-	c.conn = nil
 
-	printf("disconnected\n")
+	if c.capture != nil {
+		captureErr := c.capture.Close()
+		if err == nil {
+			err = captureErr
+		}
+		c.capture = nil
+	}
 
-	return nil
+	return err
 }
 
-func (c *Client) GetOnlineUrDragon() (*game.OnlineUrDragon, error) {
+// do runs one exchange of a public method while holding the mutex.
+// Only idempotent exchanges may be retried by a resilient client.
+func (c *Client) do(ctx context.Context, action string, idempotent bool, exchange func() error) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.cfg.Resilient {
+		return c.doResilient(ctx, idempotent, exchange)
+	}
+
 	if c.conn == nil {
-		return nil, errors.New("could not get the online ur dragon. not connected.")
+		return fmt.Errorf("could not %s. not connected.", action)
 	}
 
+	err := c.exchange(ctx, exchange)
+	if _, ok := err.(*RedirectError); ok {
+		c.close(err)
+	}
+
+	return err
+}
+
+// exchange runs the exchange within the deadline of the context. Ending the context closes the connection,
+// because an exchange that was cut off in the middle of a packet can not be resumed.
+func (c *Client) exchange(ctx context.Context, exchange func() error) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	conn := c.conn
+	if deadliner, ok := conn.ReadWriteCloser.(interface{ SetDeadline(time.Time) error }); ok {
+		deadline, _ := ctx.Deadline()
+		err = deadliner.SetDeadline(deadline)
+		if err != nil {
+			return err
+		}
+		defer deadliner.SetDeadline(time.Time{})
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	err = exchange()
+	close(stop)
+	<-stopped
+
+	ctxErr := ctx.Err()
+	if ctxErr == nil && isTimeout(err) {
+		// the read or write deadline may pass just before the context notices
+		ctxErr = context.DeadlineExceeded
+	}
+	if ctxErr != nil && (err != nil || conn.isClosed()) {
+		conn.Logger().Debugf("closing the connection: %v", ctxErr)
+		c.close(ctxErr)
+		if err != nil {
+			return ctxErr
+		}
+	}
+
+	return err
+}
+
+// GetOnlineUrDragon reads every property of the online ur dragon.
+func (c *Client) GetOnlineUrDragon(ctx context.Context) (*game.OnlineUrDragon, error) {
+	var dragon *game.OnlineUrDragon
+	err := c.do(ctx, "get the online ur dragon", true, func() error {
+		var err error
+		dragon, err = c.getOnlineUrDragon()
+		return err
+	})
+	return dragon, err
+}
+
+func (c *Client) getOnlineUrDragon() (*game.OnlineUrDragon, error) {
 	var err error
 	var response Packet
 
@@ -118,12 +286,289 @@ func (c *Client) GetOnlineUrDragon() (*game.OnlineUrDragon, error) {
 	return dragon, nil
 }
 
+// AddOnlineUrDragonProperties adds the values to the properties of the online ur dragon
+// and returns the resulting properties.
+func (c *Client) AddOnlineUrDragonProperties(ctx context.Context, props []game.DragonProperty) ([]game.DragonProperty, error) {
+	var result []game.DragonProperty
+	err := c.do(ctx, "add the online ur dragon properties", false, func() error {
+		var err error
+		result, err = c.addOnlineUrDragonProperties(props)
+		return err
+	})
+	return result, err
+}
+
+func (c *Client) addOnlineUrDragonProperties(props []game.DragonProperty) ([]game.DragonProperty, error) {
+	var err error
+	var response Packet
+
+	err = c.send(&TusCommonAreaAddRequest{PropertyPacket{Properties: dragonToNetworkProperties(props)}})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = c.recv()
+	if err != nil {
+		return nil, err
+	}
+	tusCommonAreaAddResponse, ok := response.(*TusCommonAreaAddResponse)
+	if !ok {
+		return nil, NewPacketTypeError(tusCommonAreaAddResponse, response)
+	}
+
+	return networkToDragonProperties(tusCommonAreaAddResponse.Properties), nil
+}
+
+// SetOnlineUrDragonProperties overwrites the properties of the online ur dragon
+// and returns the properties that the server accepted.
+func (c *Client) SetOnlineUrDragonProperties(ctx context.Context, props []game.DragonProperty) ([]game.DragonProperty, error) {
+	var result []game.DragonProperty
+	err := c.do(ctx, "set the online ur dragon properties", false, func() error {
+		var err error
+		result, err = c.setOnlineUrDragonProperties(props)
+		return err
+	})
+	return result, err
+}
+
+func (c *Client) setOnlineUrDragonProperties(props []game.DragonProperty) ([]game.DragonProperty, error) {
+	var err error
+	var response Packet
+
+	err = c.send(&TusCommonAreaSettingsRequest{PropertyPacket{Properties: dragonToNetworkProperties(props)}})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = c.recv()
+	if err != nil {
+		return nil, err
+	}
+	tusCommonAreaSettingsResponse, ok := response.(*TusCommonAreaSettingsResponse)
+	if !ok {
+		return nil, NewPacketTypeError(tusCommonAreaSettingsResponse, response)
+	}
+
+	return networkToDragonProperties(tusCommonAreaSettingsResponse.Properties), nil
+}
+
+// ReadUserArea reads the user area of the user in chunks and decodes it.
+func (c *Client) ReadUserArea(ctx context.Context, user string) (*UserArea, error) {
+	var area *UserArea
+	err := c.do(ctx, "read the user area", true, func() error {
+		var err error
+		area, err = c.readUserArea(user)
+		return err
+	})
+	return area, err
+}
+
+func (c *Client) readUserArea(user string) (*UserArea, error) {
+	var err error
+	var response Packet
+
+	err = c.send(&TusUserAreaReadRequestHeader{User: user})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = c.recv()
+	if err != nil {
+		return nil, err
+	}
+	tusUserAreaReadResponseHeader, ok := response.(*TusUserAreaReadResponseHeader)
+	if !ok {
+		return nil, NewPacketTypeError(tusUserAreaReadResponseHeader, response)
+	}
+
+	dataLength := int(tusUserAreaReadResponseHeader.DataLength)
+	if dataLength > maxDataLength {
+		return nil, fmt.Errorf("could not read the user area. invalid size %d.", dataLength)
+	}
+
+	assembler := newChunkAssembler(dataLength, maxChunkLength)
+	for chunkOffset := 0; chunkOffset < dataLength; chunkOffset += maxChunkLength {
+		chunkLength := dataLength - chunkOffset
+		if chunkLength > maxChunkLength {
+			chunkLength = maxChunkLength
+		}
+
+		err = c.send(&TusUserAreaReadRequestData{DataChunkReferencePacket{ChunkOffset: uint32(chunkOffset), ChunkLength: uint16(chunkLength)}})
+		if err != nil {
+			return nil, err
+		}
+
+		response, err = c.recv()
+		if err != nil {
+			return nil, err
+		}
+		tusUserAreaReadResponseData, ok := response.(*TusUserAreaReadResponseData)
+		if !ok {
+			return nil, NewPacketTypeError(tusUserAreaReadResponseData, response)
+		}
+
+		err = assembler.Write(int(tusUserAreaReadResponseData.ChunkOffset), tusUserAreaReadResponseData.ChunkData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !assembler.Complete() {
+		return nil, errors.New("could not read the user area. incomplete data.")
+	}
+
+	err = c.send(&TusUserAreaReadRequestFooter{})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = c.recv()
+	if err != nil {
+		return nil, err
+	}
+	tusUserAreaReadResponseFooter, ok := response.(*TusUserAreaReadResponseFooter)
+	if !ok {
+		return nil, NewPacketTypeError(tusUserAreaReadResponseFooter, response)
+	}
+
+	return ReadUserArea(assembler.Bytes())
+}
+
+// WriteUserArea encodes the user area and writes it for the user in the chunk length that the server asks for.
+func (c *Client) WriteUserArea(ctx context.Context, user string, area *UserArea) error {
+	return c.do(ctx, "write the user area", false, func() error {
+		return c.writeUserArea(user, area)
+	})
+}
+
+func (c *Client) writeUserArea(user string, area *UserArea) error {
+	areaData, err := WriteUserArea(area)
+	if err != nil {
+		return err
+	}
+
+	var response Packet
+
+	err = c.send(&TusUserAreaWriteRequestHeader{DataLength: uint32(len(areaData)), User: user})
+	if err != nil {
+		return err
+	}
+
+	response, err = c.recv()
+	if err != nil {
+		return err
+	}
+	tusUserAreaWriteResponseHeader, ok := response.(*TusUserAreaWriteResponseHeader)
+	if !ok {
+		return NewPacketTypeError(tusUserAreaWriteResponseHeader, response)
+	}
+
+	err = c.sendUserAreaData(areaData, int(tusUserAreaWriteResponseHeader.ChunkLength))
+	if err != nil {
+		return err
+	}
+
+	err = c.send(&TusUserAreaWriteRequestFooter{})
+	if err != nil {
+		return err
+	}
+
+	response, err = c.recv()
+	if err != nil {
+		return err
+	}
+	tusUserAreaWriteResponseFooter, ok := response.(*TusUserAreaWriteResponseFooter)
+	if !ok {
+		return NewPacketTypeError(tusUserAreaWriteResponseFooter, response)
+	}
+
+	return nil
+}
+
+func (c *Client) sendUserAreaData(areaData []byte, chunkLength int) error {
+	if chunkLength <= 0 || chunkLength > maxChunkLength {
+		return fmt.Errorf("could not write the user area. invalid chunk length %d.", chunkLength)
+	}
+
+	for chunkOffset := 0; chunkOffset < len(areaData); chunkOffset += chunkLength {
+		chunkEnd := chunkOffset + chunkLength
+		if chunkEnd > len(areaData) {
+			chunkEnd = len(areaData)
+		}
+		chunkData := areaData[chunkOffset:chunkEnd]
+
+		err := c.send(&TusUserAreaWriteRequestData{DataChunkPacket{ChunkOffset: uint32(chunkOffset), ChunkData: chunkData}})
+		if err != nil {
+			return err
+		}
+
+		response, err := c.recv()
+		if err != nil {
+			return err
+		}
+		tusUserAreaWriteResponseData, ok := response.(*TusUserAreaWriteResponseData)
+		if !ok {
+			return NewPacketTypeError(tusUserAreaWriteResponseData, response)
+		}
+
+		if int(tusUserAreaWriteResponseData.ChunkOffset) != chunkOffset || int(tusUserAreaWriteResponseData.ChunkLength) != len(chunkData) {
+			return errors.New("could not write the user area. chunk mismatch.")
+		}
+	}
+
+	return nil
+}
+
+// OnlineCheck asks the server whether the session is still alive.
+// Online checks of the server that arrive in the meantime are answered.
+func (c *Client) OnlineCheck(ctx context.Context) error {
+	return c.do(ctx, "check online", true, func() error {
+		return c.onlineCheck()
+	})
+}
+
+func (c *Client) onlineCheck() error {
+	err := c.send(&OnlineCheckRequest{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		// the keep alive of the connection swallows online check responses, so they are received directly
+		response, err := c.conn.recv()
+		if err != nil {
+			return err
+		}
+		response, err = c.followRedirect(response)
+		if err != nil {
+			return err
+		}
+
+		switch response.(type) {
+		case *OnlineCheckResponse:
+			return nil
+		case *OnlineCheckRequest:
+			err = c.send(&OnlineCheckResponse{})
+			if err != nil {
+				return err
+			}
+		default:
+			return NewPacketTypeError(&OnlineCheckResponse{}, response)
+		}
+	}
+}
+
 func (c *Client) recv() (Packet, error) {
 	if c.conn == nil {
 		return nil, errors.New("could not receive data. not connected.")
 	}
 
-	return c.conn.Recv()
+	packet, err := c.conn.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.followRedirect(packet)
 }
 
 func (c *Client) send(packet Packet) error {
@@ -135,10 +580,6 @@ func (c *Client) send(packet Packet) error {
 }
 
 func (c *Client) authenticate() error {
-	if c.conn == nil {
-		return errors.New("could not authenticate. not connected.")
-	}
-
 	var err error
 	var response Packet
 
@@ -159,10 +600,16 @@ func (c *Client) authenticate() error {
 	if err != nil {
 		return err
 	}
+	if disconnectionNotification, ok := response.(*DisconnectionNotification); ok {
+		return &LoginRefusedError{Message: disconnectionNotification.Notification}
+	}
 	connectionSummaryNotification, ok := response.(*ConnectionSummaryNotification)
 	if !ok {
 		return NewPacketTypeError(connectionSummaryNotification, response)
 	}
+	if !connectionSummaryNotification.Success {
+		return &LoginRefusedError{}
+	}
 
 	err = c.send(&AuthenticationInformationRequestHeader{Unknown: 0x02, DataLength: uint32(len(c.cfg.UserToken))})
 	if err != nil {
@@ -177,18 +624,10 @@ func (c *Client) authenticate() error {
 		return NewPacketTypeError(authenticationInformationResponseHeader, response)
 	}
 
-	err = c.send(&AuthenticationInformationRequestData{DataChunkPacket{ChunkData: c.cfg.UserToken}})
+	err = c.sendAuthenticationData(c.cfg.UserToken, int(authenticationInformationResponseHeader.ChunkLength))
 	if err != nil {
 		return err
 	}
-	response, err = c.recv()
-	if err != nil {
-		return err
-	}
-	authenticationInformationResponseData, ok := response.(*AuthenticationInformationResponseData)
-	if !ok {
-		return NewPacketTypeError(authenticationInformationResponseData, response)
-	}
 
 	err = c.send(&AuthenticationInformationRequestFooter{})
 	if err != nil {
@@ -203,5 +642,47 @@ func (c *Client) authenticate() error {
 		return NewPacketTypeError(authenticationInformationResponseFooter, response)
 	}
 
+	if !authenticationInformationResponseFooter.Value {
+		return ErrTokenRejected
+	}
+
 	return nil
 }
+
+func (c *Client) sendAuthenticationData(token []byte, chunkLength int) error {
+	if chunkLength <= 0 {
+		return errors.New("could not authenticate. invalid chunk length.")
+	}
+
+	chunkOffset := 0
+	for {
+		chunkEnd := chunkOffset + chunkLength
+		if chunkEnd > len(token) {
+			chunkEnd = len(token)
+		}
+		chunkData := token[chunkOffset:chunkEnd]
+
+		err := c.send(&AuthenticationInformationRequestData{DataChunkPacket{ChunkOffset: uint32(chunkOffset), ChunkData: chunkData}})
+		if err != nil {
+			return err
+		}
+
+		response, err := c.recv()
+		if err != nil {
+			return err
+		}
+		authenticationInformationResponseData, ok := response.(*AuthenticationInformationResponseData)
+		if !ok {
+			return NewPacketTypeError(authenticationInformationResponseData, response)
+		}
+
+		if int(authenticationInformationResponseData.ChunkOffset) != chunkOffset || int(authenticationInformationResponseData.ChunkLength) != len(chunkData) {
+			return errors.New("could not authenticate. chunk mismatch.")
+		}
+
+		chunkOffset = chunkEnd
+		if chunkOffset >= len(token) {
+			return nil
+		}
+	}
+}
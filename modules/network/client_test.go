@@ -0,0 +1,140 @@
+package network
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
+)
+
+func TestClientSession(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	c := &Client{conn: conn}
+	ctx := context.Background()
+
+	err := c.OnlineCheck(ctx)
+	if err != nil {
+		t.Errorf("online check failed: %v", err)
+		return
+	}
+
+	props, err := c.AddOnlineUrDragonProperties(ctx, []game.DragonProperty{{Index: 31, Value2: 2}})
+	if err != nil {
+		t.Errorf("add failed: %v", err)
+		return
+	}
+	if len(props) != 1 || props[0].Index != 31 || props[0].Value2 != 2 {
+		t.Errorf("unexpected properties after add %v", props)
+	}
+
+	_, err = c.SetOnlineUrDragonProperties(ctx, []game.DragonProperty{{Index: 33, Value2: 5}})
+	if err != nil {
+		t.Errorf("set failed: %v", err)
+		return
+	}
+
+	dragon, err := c.GetOnlineUrDragon(ctx)
+	if err != nil {
+		t.Errorf("get failed: %v", err)
+		return
+	}
+	if dragon.FightCount != 2 {
+		t.Errorf("unexpected fight count %d", dragon.FightCount)
+	}
+
+	area := NewUserArea()
+	area.Revision = 3
+	area.Slots[0].IsFree = 0
+	area.Slots[0].User = 0x0110000100000002
+	area.Slots[0].Items[0] = 42
+	area.Slots[0].ItemsCount = 1
+
+	err = c.WriteUserArea(ctx, "0110000100000001", area)
+	if err != nil {
+		t.Errorf("write failed: %v", err)
+		return
+	}
+
+	readArea, err := c.ReadUserArea(ctx, "0110000100000001")
+	if err != nil {
+		t.Errorf("read failed: %v", err)
+		return
+	}
+
+	if readArea.Revision != 3 || readArea.Slots[0].User != area.Slots[0].User || readArea.Slots[0].Items[0] != 42 || readArea.Slots[0].ItemsCount != 1 {
+		t.Errorf("unexpected user area %+v", readArea.Slots[0])
+	}
+
+	_, err = c.ReadUserArea(ctx, "not hex")
+	if protocolErr, ok := err.(*ProtocolError); !ok || protocolErr.ErrorID != invalidUserErrorID {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	err = c.Disconnect(ctx)
+	if err != nil {
+		t.Errorf("disconnect failed: %v", err)
+	}
+}
+
+func TestClientConcurrentCalls(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	c := &Client{conn: conn}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetOnlineUrDragon(context.Background())
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent call failed: %v", err)
+		}
+	}
+}
+
+func TestClientContextCloses(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	go io.Copy(ioutil.Discard, serverSide)
+
+	c := &Client{conn: NewClientConn(clientSide, 0, false)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.GetOnlineUrDragon(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if c.conn != nil {
+		t.Error("the connection was not closed")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	err = c.OnlineCheck(ctx)
+	if err == nil {
+		t.Error("a closed client answered")
+	}
+}
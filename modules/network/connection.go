@@ -6,15 +6,26 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
 )
 
-var localSequenceIDRand = rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
+var (
+	localSequenceIDMutex sync.Mutex
+	localSequenceIDRand  = rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
+)
 
 func newLocalSequenceID() uint16 {
+	localSequenceIDMutex.Lock()
+	defer localSequenceIDMutex.Unlock()
 	return uint16(localSequenceIDRand.Uint32())
 }
 
+const packetHeaderLength = 8
+
 type ClientConn struct {
 	io.ReadWriteCloser
 	ID               int64
@@ -22,6 +33,17 @@ type ClientConn struct {
 	LocalSequenceID  uint16
 	RemoteSequenceID uint16
 	ToRemoteClient   bool
+	sequenceMutex    sync.Mutex
+	writeMutex       sync.Mutex
+	connectedAt      time.Time
+	lastPacketTime   time.Time
+	lastPacketType   PacketType
+	closed           int32
+	log              *logging.Logger
+	keepAlive        *keepAlive
+	capture          *CaptureWriter
+	headerBuffer     [packetHeaderLength]byte
+	headerOffset     int
 }
 
 func NewClientConn(rw io.ReadWriteCloser, ID int64, toRemoteClient bool) *ClientConn {
@@ -30,9 +52,21 @@ func NewClientConn(rw io.ReadWriteCloser, ID int64, toRemoteClient bool) *Client
 		ID:              ID,
 		LocalSequenceID: newLocalSequenceID(),
 		ToRemoteClient:  toRemoteClient,
+		connectedAt:     time.Now().UTC(),
 	}
 }
 
+// Close marks the connection as closed on purpose before closing it,
+// so that the goroutine receiving on it can tell a kick or a shutdown from a failure.
+func (conn *ClientConn) Close() error {
+	atomic.StoreInt32(&conn.closed, 1)
+	return conn.ReadWriteCloser.Close()
+}
+
+func (conn *ClientConn) isClosed() bool {
+	return atomic.LoadInt32(&conn.closed) != 0
+}
+
 func (conn *ClientConn) String() string {
 	if len(conn.User) > 0 {
 		return fmt.Sprintf("[%d/%s]", conn.ID, conn.User)
@@ -41,6 +75,7 @@ func (conn *ClientConn) String() string {
 	return fmt.Sprintf("[%d]", conn.ID)
 }
 
+// Send is safe to call from several goroutines. Packets are numbered in the order they are written.
 func (conn *ClientConn) Send(packet Packet) error {
 	payload, err := packet.Payload()
 	if err != nil {
@@ -52,42 +87,83 @@ func (conn *ClientConn) Send(packet Packet) error {
 		return NewPayloadError(packetLength, maxPacketLength)
 	}
 
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+
 	var header PacketHeader
 	header.Length = uint16(packetLength)
 	header.PacketType = GetPacketType(packet)
 
-	if conn.ToRemoteClient && header.PacketType.TypeID == responseID || !conn.ToRemoteClient && header.PacketType.TypeID != responseID {
-		conn.LocalSequenceID++
-		header.SequenceID = conn.LocalSequenceID
-	} else {
-		header.SequenceID = conn.RemoteSequenceID
-	}
+	header.SequenceID = conn.nextSequenceID(header.PacketType)
 
 	packet.SetHeader(header)
 
-	printf("%v sending %v\n", conn, &header)
+	if conn.log.Enabled(logging.DebugLevel) {
+		conn.packetLogger(&header).Debugf("sending %v", &header)
+	}
+
+	var packetBuffer bytes.Buffer
+	err = binary.Write(&packetBuffer, binary.BigEndian, header)
+	if err != nil {
+		return err
+	}
 
-	err = binary.Write(conn, binary.BigEndian, header)
+	_, err = packetBuffer.Write(payload)
 	if err != nil {
 		return err
 	}
 
-	err = binary.Write(conn, binary.BigEndian, payload)
+	_, err = conn.Write(packetBuffer.Bytes())
 	if err != nil {
 		return err
 	}
 
+	conn.record(true, header, payload)
+
 	return nil
 }
 
+// nextSequenceID numbers packets from the local side and answers packets from the remote side with their id.
+// The ids are guarded because a proxy sends on a connection while another goroutine receives on it.
+func (conn *ClientConn) nextSequenceID(packetType PacketType) uint16 {
+	conn.sequenceMutex.Lock()
+	defer conn.sequenceMutex.Unlock()
+
+	if conn.ToRemoteClient && packetType.TypeID == responseID || !conn.ToRemoteClient && packetType.TypeID != responseID {
+		conn.LocalSequenceID++
+		return conn.LocalSequenceID
+	}
+
+	return conn.RemoteSequenceID
+}
+
 func (conn *ClientConn) Recv() (Packet, error) {
+	if conn.keepAlive != nil {
+		return conn.keepAlive.recv(conn)
+	}
+
+	return conn.recv()
+}
+
+func (conn *ClientConn) recv() (Packet, error) {
+	for conn.headerOffset < len(conn.headerBuffer) {
+		n, err := conn.Read(conn.headerBuffer[conn.headerOffset:])
+		conn.headerOffset += n
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn.headerOffset = 0
+
 	var header PacketHeader
-	err := binary.Read(conn, binary.BigEndian, &header)
+	err := binary.Read(bytes.NewReader(conn.headerBuffer[:]), binary.BigEndian, &header)
 	if err != nil {
 		return nil, err
 	}
 
-	printf("%v receiving %v\n", conn, &header)
+	if conn.log.Enabled(logging.DebugLevel) {
+		conn.packetLogger(&header).Debugf("receiving %v", &header)
+	}
 
 	packet, err := NewPacketFromHeader(header)
 	if err != nil {
@@ -98,6 +174,10 @@ func (conn *ClientConn) Recv() (Packet, error) {
 	payloadLength := int64(header.Length)
 	n, err := io.CopyN(&payloadBuffer, conn, payloadLength)
 	if err != nil {
+		if isTimeout(err) {
+			return nil, fmt.Errorf("incomplete %v payload: %v", &header, err)
+		}
+
 		return nil, err
 	}
 
@@ -105,12 +185,22 @@ func (conn *ClientConn) Recv() (Packet, error) {
 		return nil, NewPayloadError(int(n), int(payloadLength))
 	}
 
+	conn.record(false, header, payloadBuffer.Bytes())
+
 	err = packet.SetPayload(payloadBuffer.Bytes())
 	if err != nil {
 		return nil, err
 	}
 
+	conn.sequenceMutex.Lock()
 	conn.RemoteSequenceID = header.SequenceID
+	conn.lastPacketTime = time.Now().UTC()
+	conn.lastPacketType = header.PacketType
+	conn.sequenceMutex.Unlock()
+
+	if errorResponse, ok := packet.(*ErrorResponse); ok {
+		return nil, NewProtocolError(errorResponse)
+	}
 
 	return packet, nil
 }
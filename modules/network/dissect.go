@@ -0,0 +1,171 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Frame is a single packet split from a raw protocol stream.
+// Unregistered packets are decoded as UnknownPacket.
+// Packet is nil and Err is set if the payload could not be decoded.
+type Frame struct {
+	Offset  int64
+	Header  PacketHeader
+	Payload []byte
+	Packet  Packet
+	Err     error
+}
+
+// FrameReader splits a raw stream into packets the same way ClientConn.Recv does
+// but keeps going when a packet is malformed.
+type FrameReader struct {
+	reader io.Reader
+	offset int64
+}
+
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{reader: r}
+}
+
+// ReadFrame returns the next frame or io.EOF at the end of the stream.
+func (r *FrameReader) ReadFrame() (*Frame, error) {
+	var headerBuffer [packetHeaderLength]byte
+	n, err := io.ReadFull(r.reader, headerBuffer[:])
+	if err == io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("truncated packet header at offset %d", r.offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	frame := &Frame{Offset: r.offset}
+	r.offset += int64(n)
+
+	err = binary.Read(bytes.NewReader(headerBuffer[:]), binary.BigEndian, &frame.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	frame.Payload = make([]byte, int(frame.Header.Length))
+	n, err = io.ReadFull(r.reader, frame.Payload)
+	r.offset += int64(n)
+	if err != nil {
+		return nil, fmt.Errorf("truncated %v payload at offset %d: %v", &frame.Header, frame.Offset, err)
+	}
+
+	frame.Packet, frame.Err = DecodePacket(frame.Header, frame.Payload)
+
+	return frame, nil
+}
+
+// DecodePacket creates the registered packet of the header and reads its payload.
+func DecodePacket(header PacketHeader, payload []byte) (Packet, error) {
+	packet, err := NewPacketFromHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	err = packet.SetPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return packet, nil
+}
+
+// SequenceValidator checks the sequence ids of both directions of a session.
+// Every side numbers the packets it initiates consecutively, which are the requests and notifications of the client
+// and the responses of the server. All other packets repeat the id of the last packet received from the other side.
+type SequenceValidator struct {
+	numbered map[CaptureDirection]uint16
+	received map[CaptureDirection]uint16
+}
+
+func NewSequenceValidator() *SequenceValidator {
+	return &SequenceValidator{
+		numbered: make(map[CaptureDirection]uint16),
+		received: make(map[CaptureDirection]uint16),
+	}
+}
+
+func (v *SequenceValidator) Validate(direction CaptureDirection, header PacketHeader) error {
+	var other CaptureDirection
+	switch direction {
+	case ClientToServer:
+		other = ServerToClient
+	case ServerToClient:
+		other = ClientToServer
+	default:
+		return fmt.Errorf("invalid direction %v", direction)
+	}
+
+	isResponse := header.PacketType.TypeID == responseID
+	numbered := direction == ClientToServer && !isResponse || direction == ServerToClient && isResponse
+
+	var err error
+	if numbered {
+		last, ok := v.numbered[direction]
+		if ok && header.SequenceID != last+1 {
+			err = fmt.Errorf("%v sequence id %d does not follow %d", direction, header.SequenceID, last)
+		}
+		v.numbered[direction] = header.SequenceID
+	} else if last, ok := v.received[other]; ok && header.SequenceID != last {
+		err = fmt.Errorf("%v sequence id %d does not repeat %d", direction, header.SequenceID, last)
+	}
+
+	v.received[direction] = header.SequenceID
+
+	return err
+}
+
+type PacketField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PacketFields lists the payload fields of a packet with their values.
+func PacketFields(packet Packet) []PacketField {
+	return appendFields(nil, "", reflect.ValueOf(packet))
+}
+
+func appendFields(fields []PacketField, name string, v reflect.Value) []PacketField {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return append(fields, PacketField{name, "nil"})
+		}
+
+		return appendFields(fields, name, v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.Type == packetHeaderType || f.PkgPath != "" || f.Name == "_" {
+				continue
+			}
+
+			fieldName := f.Name
+			if f.Anonymous {
+				fieldName = ""
+			}
+
+			fields = appendFields(fields, joinField(name, fieldName), v.Field(i))
+		}
+
+		return fields
+	case reflect.Slice, reflect.Array:
+		if v.Type() == byteSliceType {
+			return append(fields, PacketField{name, formatValue(v)})
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			fields = appendFields(fields, fmt.Sprintf("%s[%d]", name, i), v.Index(i))
+		}
+
+		return fields
+	default:
+		return append(fields, PacketField{name, formatValue(v)})
+	}
+}
@@ -0,0 +1,130 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func writeFrame(t *testing.T, w io.Writer, header PacketHeader, payload []byte) {
+	header.Length = uint16(len(payload))
+	err := binary.Write(w, binary.BigEndian, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.Write(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFrameReaderKeepsUnknownPackets(t *testing.T) {
+	var stream bytes.Buffer
+	requestPayload, _ := (&TusCommonAreaAcquisitionRequest{PropertyIndices: []byte{1, 2}}).Payload()
+	writeFrame(t, &stream, PacketHeader{SequenceID: 1, PacketType: PacketType{tusCommonAreaAcquisitionID, requestID, noErrorID}}, requestPayload)
+	writeFrame(t, &stream, PacketHeader{SequenceID: 2, PacketType: PacketType{0x1301, requestID, noErrorID}}, []byte{0xAB, 0xCD})
+	writeFrame(t, &stream, PacketHeader{SequenceID: 3, PacketType: PacketType{onlineCheckID, requestID, noErrorID}}, nil)
+
+	reader := NewFrameReader(&stream)
+
+	frame, err := reader.ReadFrame()
+	if err != nil || frame.Err != nil {
+		t.Errorf("failed to read known frame: %v %v", err, frame.Err)
+		return
+	}
+
+	fields := PacketFields(frame.Packet)
+	if len(fields) != 1 || fields[0].Name != "PropertyIndices" || fields[0].Value != "0102" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	frame, err = reader.ReadFrame()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	unknownPacket, ok := frame.Packet.(*UnknownPacket)
+	if err != nil || !ok || !bytes.Equal(unknownPacket.Data, []byte{0xAB, 0xCD}) || frame.Offset != int64(packetHeaderLength+len(requestPayload)) {
+		t.Errorf("unexpected unknown frame %v", frame)
+	}
+
+	frame, err = reader.ReadFrame()
+	if err != nil || frame.Err != nil {
+		t.Errorf("failed to read frame after unknown frame: %v", err)
+		return
+	}
+
+	if _, ok := frame.Packet.(*OnlineCheckRequest); !ok {
+		t.Errorf("unexpected packet %T", frame.Packet)
+	}
+
+	_, err = reader.ReadFrame()
+	if err != io.EOF {
+		t.Errorf("expected the end of the stream, got %v", err)
+	}
+}
+
+func TestSequenceValidatorAcceptsSession(t *testing.T) {
+	var buffer bytes.Buffer
+	capture, _ := NewCaptureWriter(&buffer)
+
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	client := &Client{
+		cfg:  ClientConfig{User: "0110000100000001", UserToken: make([]byte, 100)},
+		conn: NewClientConn(clientSide, 0, false),
+	}
+
+	clientErrs := make(chan error, 1)
+	go func() {
+		clientErrs <- client.authenticate()
+	}()
+
+	s := newServer(ServerConfig{AuthChunkLength: 32}, nil)
+	s.capture = capture
+	_, err := s.authenticate(serverSide, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err = <-clientErrs; err != nil {
+		t.Error(err)
+		return
+	}
+
+	reader, err := NewCaptureReader(&buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	validator := NewSequenceValidator()
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		err = validator.Validate(record.Direction, record.Header)
+		if err != nil {
+			t.Errorf("%v: %v", &record.Header, err)
+		}
+	}
+
+	header := PacketHeader{SequenceID: client.conn.LocalSequenceID + 2, PacketType: PacketType{tusCommonAreaAcquisitionID, requestID, noErrorID}}
+	err = validator.Validate(ClientToServer, header)
+	if err == nil {
+		t.Error("skipped sequence id was accepted")
+	}
+}
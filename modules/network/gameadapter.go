@@ -20,6 +20,16 @@ func dragonToNetworkProperties(dragonProps []game.DragonProperty) []Property {
 	return props
 }
 
+func validPropertyIndices(dragonProps []game.DragonProperty) []byte {
+	indices := make([]byte, 0, len(dragonProps))
+	for _, prop := range dragonProps {
+		if prop.Index < game.UsedDragonProperties {
+			indices = append(indices, prop.Index)
+		}
+	}
+	return indices
+}
+
 func userAreaToPawnRewards(userID uint64, area *UserArea) *game.PawnRewards {
 	if area == nil {
 		return nil
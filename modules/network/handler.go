@@ -0,0 +1,93 @@
+package network
+
+import (
+	"errors"
+)
+
+var ErrClientDisconnected = errors.New("client disconnected")
+
+type Handler interface {
+	ServePacket(client *ClientConn, request Packet) error
+}
+
+type HandlerFunc func(client *ClientConn, request Packet) error
+
+func (f HandlerFunc) ServePacket(client *ClientConn, request Packet) error {
+	return f(client, request)
+}
+
+type Middleware func(next Handler) Handler
+
+// Handle registers the handler for all packets with the given name id.
+// A handler returns a *RequestError to answer the request with an error response,
+// or ErrClientDisconnected to end the session.
+func (s *Server) Handle(nameID PacketNameID, handler Handler) {
+	s.handlersMutex.Lock()
+	defer s.handlersMutex.Unlock()
+
+	s.handlers[nameID] = handler
+}
+
+func (s *Server) HandleFunc(nameID PacketNameID, handler func(client *ClientConn, request Packet) error) {
+	s.Handle(nameID, HandlerFunc(handler))
+}
+
+// Use wraps every handler with the middlewares. The first middleware is the outermost one.
+func (s *Server) Use(middlewares ...Middleware) {
+	s.handlersMutex.Lock()
+	defer s.handlersMutex.Unlock()
+
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+func (s *Server) dispatch(client *ClientConn, request Packet) error {
+	s.handlersMutex.RLock()
+	handler, ok := s.handlers[GetPacketType(request).NameID]
+	if !ok {
+		handler = HandlerFunc(s.unhandledRequest)
+	}
+
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		handler = s.middlewares[i](handler)
+	}
+	s.handlersMutex.RUnlock()
+
+	return handler.ServePacket(client, request)
+}
+
+func (s *Server) unhandledRequest(client *ClientConn, request Packet) error {
+	if unknownPacket, ok := request.(*UnknownPacket); ok {
+		return s.handleUnknownPacket(client, unknownPacket)
+	}
+
+	packetType := GetPacketType(request)
+	log := client.Logger().WithFields(packetLogFields(packetType))
+	log.Warnf("unhandled request %v", &packetType)
+
+	err := disconnect(client, "")
+	if err != nil {
+		log.Warnf("disconnect failed: %v", err)
+		return err
+	}
+
+	return ErrClientDisconnected
+}
+
+// handleUnknownPacket archives the packet and refuses it without ending the session.
+func (s *Server) handleUnknownPacket(client *ClientConn, packet *UnknownPacket) error {
+	log := client.packetLogger(&packet.PacketHeader)
+	if s.unknownPackets != nil {
+		path, err := s.unknownPackets.Archive(client, packet)
+		if err == ErrUnknownPacketArchiveFull {
+			log.Debugf("did not archive unknown packet %v: %v", &packet.PacketType, err)
+		} else if err != nil {
+			log.Warnf("failed to archive unknown packet %v: %v", &packet.PacketType, err)
+		} else {
+			log.Infof("archived unknown packet %v to %s", &packet.PacketType, path)
+		}
+	} else {
+		log.Infof("unknown packet %v", &packet.PacketType)
+	}
+
+	return NewRequestError(packet, unknownPacketErrorID, errors.New("no handler for unknown packet"))
+}
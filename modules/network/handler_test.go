@@ -0,0 +1,171 @@
+package network
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHandleOverridesDefaultHandler(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(client *ClientConn, request Packet) error {
+				order = append(order, name)
+				return next.ServePacket(client, request)
+			})
+		}
+	}
+	s.Use(trace("outer"), trace("inner"))
+
+	s.HandleFunc(tusCommonAreaAcquisitionID, func(client *ClientConn, request Packet) error {
+		order = append(order, "handler")
+		return client.Send(&TusCommonAreaAcquisitionResponse{PropertyPacket{Properties: []Property{{Index: 1, Value1: 2, Value2: 3}}}})
+	})
+
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&TusCommonAreaAcquisitionRequest{PropertyIndices: []byte{1}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	response, err := conn.Recv()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	acquisitionResponse, ok := response.(*TusCommonAreaAcquisitionResponse)
+	if !ok || len(acquisitionResponse.Properties) != 1 || acquisitionResponse.Properties[0].Value1 != 2 {
+		t.Errorf("unexpected response %v", response)
+	}
+
+	if !reflect.DeepEqual(order, []string{"outer", "inner", "handler"}) {
+		t.Errorf("unexpected middleware order %v", order)
+	}
+}
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	s.Use(AuthorizationMiddleware(func(client *ClientConn, request Packet) error {
+		if _, ok := request.(*TusCommonAreaSettingsRequest); ok {
+			return errors.New("read only")
+		}
+
+		return nil
+	}))
+
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&TusCommonAreaSettingsRequest{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = conn.Recv()
+	protocolErr, ok := err.(*ProtocolError)
+	if !ok || protocolErr.ErrorID != notAuthorizedErrorID {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	err = conn.Send(&TusCommonAreaAcquisitionRequest{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = conn.Recv()
+	if err != nil {
+		t.Errorf("authorized request failed: %v", err)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	s.Use(RateLimitMiddleware(0, 2))
+
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	for i := 0; i < 3; i++ {
+		err := conn.Send(&TusCommonAreaAcquisitionRequest{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		_, err = conn.Recv()
+		protocolErr, limited := err.(*ProtocolError)
+		if i < 2 && err != nil {
+			t.Errorf("request %d failed: %v", i, err)
+		}
+		if i == 2 && (!limited || protocolErr.ErrorID != rateLimitedErrorID) {
+			t.Errorf("request %d was not rate limited: %v", i, err)
+		}
+	}
+}
+
+func TestServerRequestMetrics(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	s.Use(AuthorizationMiddleware(func(client *ClientConn, request Packet) error {
+		return errors.New("read only")
+	}))
+
+	packet := packetName(tusCommonAreaSettingsID)
+	refused := requestsTotal.With(packet, "0x06")
+	refusedBefore := refused.Value()
+	durationBefore := requestDuration.With(packet).Count()
+
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&TusCommonAreaSettingsRequest{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = conn.Recv()
+	if _, ok := err.(*ProtocolError); !ok {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if refused.Value() != refusedBefore+1 || requestDuration.With(packet).Count() != durationBefore+1 {
+		t.Errorf("request was not observed: %v refused, %d durations", refused.Value(), requestDuration.With(packet).Count())
+	}
+}
+
+func TestServerRequestMetricsUnknownPackets(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	durationBefore := requestDuration.With(unknownPacketLabel).Count()
+
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	for _, nameID := range []PacketNameID{0x1301, 0x1302} {
+		unknownPacket := &UnknownPacket{}
+		unknownPacket.PacketType = PacketType{nameID, requestID, noErrorID}
+		err := conn.Send(unknownPacket)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		_, err = conn.Recv()
+		if _, ok := err.(*ProtocolError); !ok {
+			t.Errorf("unexpected error %v", err)
+			return
+		}
+	}
+
+	if count := requestDuration.With(unknownPacketLabel).Count(); count != durationBefore+2 {
+		t.Errorf("unknown packets were not observed under one label: %d %d", count, durationBefore+2)
+	}
+}
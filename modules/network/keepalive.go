@@ -0,0 +1,101 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+const (
+	defaultOnlineCheckInterval = 60 * time.Second
+	defaultOnlineCheckTimeout  = 30 * time.Second
+	disconnectWriteTimeout     = 5 * time.Second
+)
+
+var ErrOnlineCheckTimeout = errors.New("online check timed out")
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+type keepAlive struct {
+	interval time.Duration
+	timeout  time.Duration
+	probing  bool
+}
+
+// EnableKeepAlive answers online checks of the remote side.
+// A positive interval additionally sends an online check after the connection has been idle for that long
+// and fails the next Recv with ErrOnlineCheckTimeout if no packet arrives within the timeout.
+// Probing requires the underlying connection to support read deadlines.
+func (conn *ClientConn) EnableKeepAlive(interval time.Duration, timeout time.Duration) {
+	conn.keepAlive = &keepAlive{
+		interval: interval,
+		timeout:  timeout,
+	}
+}
+
+func (k *keepAlive) recv(conn *ClientConn) (Packet, error) {
+	deadliner, canProbe := conn.ReadWriteCloser.(readDeadliner)
+	canProbe = canProbe && k.interval > 0
+
+	if canProbe {
+		defer deadliner.SetReadDeadline(time.Time{})
+	}
+
+	for {
+		if canProbe {
+			wait := k.interval
+			if k.probing {
+				wait = k.timeout
+			}
+
+			err := deadliner.SetReadDeadline(time.Now().Add(wait))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		packet, err := conn.recv()
+		if err != nil {
+			if !canProbe || !isTimeout(err) || conn.headerOffset != 0 {
+				return nil, err
+			}
+
+			if k.probing {
+				return nil, ErrOnlineCheckTimeout
+			}
+
+			conn.Logger().Debugf("idle, sending online check")
+			err = conn.Send(&OnlineCheckRequest{})
+			if err != nil {
+				return nil, err
+			}
+
+			k.probing = true
+			continue
+		}
+
+		k.probing = false
+
+		switch packet.(type) {
+		case *OnlineCheckRequest:
+			err = conn.Send(&OnlineCheckResponse{})
+			if err != nil {
+				return nil, err
+			}
+		case *OnlineCheckResponse:
+		default:
+			return packet, nil
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestKeepAliveTimeout(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	server := NewClientConn(serverSide, 1, true)
+	server.EnableKeepAlive(20*time.Millisecond, 20*time.Millisecond)
+	client := NewClientConn(clientSide, 0, false)
+
+	probes := make(chan Packet, 1)
+	go func() {
+		packet, err := client.Recv()
+		if err == nil {
+			probes <- packet
+		}
+		close(probes)
+	}()
+
+	_, err := server.Recv()
+	if err != ErrOnlineCheckTimeout {
+		t.Errorf("unexpected error %v expected %v", err, ErrOnlineCheckTimeout)
+	}
+
+	probe := <-probes
+	if _, ok := probe.(*OnlineCheckRequest); !ok {
+		t.Errorf("unexpected probe %T", probe)
+	}
+}
+
+func TestKeepAliveAnswered(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	server := NewClientConn(serverSide, 1, true)
+	server.EnableKeepAlive(10*time.Millisecond, 50*time.Millisecond)
+	client := NewClientConn(clientSide, 0, false)
+	client.EnableKeepAlive(0, 0)
+
+	go client.Recv()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := server.Recv()
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		t.Errorf("connection failed although online checks were answered: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
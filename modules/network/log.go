@@ -0,0 +1,42 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+)
+
+// SetLogger sets the logger that Logger derives the connection fields from.
+func (conn *ClientConn) SetLogger(log *logging.Logger) {
+	conn.log = log
+}
+
+// Logger returns a logger with the connection id, user and sequence ids as fields.
+func (conn *ClientConn) Logger() *logging.Logger {
+	conn.sequenceMutex.Lock()
+	fields := logging.Fields{
+		"conn":      conn.ID,
+		"localSeq":  conn.LocalSequenceID,
+		"remoteSeq": conn.RemoteSequenceID,
+	}
+	conn.sequenceMutex.Unlock()
+
+	if len(conn.User) > 0 {
+		fields["user"] = conn.User
+	}
+
+	return conn.log.WithFields(fields)
+}
+
+func packetLogFields(packetType PacketType) logging.Fields {
+	return logging.Fields{
+		"nameId": fmt.Sprintf("0x%04x", uint16(packetType.NameID)),
+		"typeId": fmt.Sprintf("0x%02x", uint8(packetType.TypeID)),
+	}
+}
+
+func (conn *ClientConn) packetLogger(header *PacketHeader) *logging.Logger {
+	fields := packetLogFields(header.PacketType)
+	fields["seq"] = header.SequenceID
+	return conn.Logger().WithFields(fields)
+}
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrMaintenance = errors.New("login refused during maintenance")
+
+// LoginRefusedError is returned by the client when the server refuses the login after the FastData exchange,
+// for example during maintenance.
+type LoginRefusedError struct {
+	Message string
+}
+
+func (e *LoginRefusedError) Error() string {
+	if len(e.Message) == 0 {
+		return "login refused"
+	}
+
+	return fmt.Sprintf("login refused: %s", e.Message)
+}
+
+// Maintenance refuses new logins after the FastData exchange.
+// Clients are refused with a DisconnectionNotification that carries the Message,
+// or with a failing ConnectionSummaryNotification if there is no message.
+// Sessions that exist when maintenance is enabled are disconnected with the message unless KeepSessions is set.
+type Maintenance struct {
+	Enabled      bool   `json:"enabled"`
+	Message      string `json:"message,omitempty"`
+	KeepSessions bool   `json:"keepSessions"`
+}
+
+func (s *Server) Maintenance() Maintenance {
+	s.maintenanceMutex.RLock()
+	defer s.maintenanceMutex.RUnlock()
+	return s.maintenance
+}
+
+// SetMaintenance returns the number of sessions that were disconnected.
+func (s *Server) SetMaintenance(maintenance Maintenance) int {
+	s.maintenanceMutex.Lock()
+	s.maintenance = maintenance
+	s.maintenanceMutex.Unlock()
+
+	if !maintenance.Enabled {
+		s.config.Logger.Infof("maintenance disabled")
+		return 0
+	}
+
+	s.config.Logger.WithField("keepSessions", maintenance.KeepSessions).Infof("maintenance enabled: %s", maintenance.Message)
+	if maintenance.KeepSessions {
+		return 0
+	}
+
+	disconnected := 0
+	for _, client := range s.connectedClients() {
+		err := disconnectAndClose(client, maintenance.Message)
+		if err != nil {
+			client.Logger().Debugf("failed to send the maintenance notification: %v", err)
+		}
+		disconnected++
+	}
+
+	return disconnected
+}
+
+func refuseLogin(client *ClientConn, maintenance Maintenance) error {
+	if len(maintenance.Message) == 0 {
+		return client.Send(&ConnectionSummaryNotification{Success: false, Unknown: 10})
+	}
+
+	return disconnect(client, maintenance.Message)
+}
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"testing"
+)
+
+func TestMaintenanceRefusesLogin(t *testing.T) {
+	_, serverErr, clientErr := authenticatePipe(t,
+		ServerConfig{Maintenance: Maintenance{Enabled: true, Message: "database work"}},
+		ClientConfig{User: "0110000100000001"})
+
+	if serverErr != ErrMaintenance {
+		t.Errorf("unexpected server error %v", serverErr)
+	}
+
+	refused, ok := clientErr.(*LoginRefusedError)
+	if !ok || refused.Message != "database work" {
+		t.Errorf("unexpected client error %v", clientErr)
+	}
+
+	_, serverErr, clientErr = authenticatePipe(t,
+		ServerConfig{Maintenance: Maintenance{Enabled: true}},
+		ClientConfig{User: "0110000100000001"})
+
+	if _, ok := clientErr.(*LoginRefusedError); !ok || serverErr != ErrMaintenance {
+		t.Errorf("unexpected errors %v %v", serverErr, clientErr)
+	}
+}
+
+func TestMaintenanceDisconnectsSessions(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&TusCommonAreaAcquisitionRequest{PropertyIndices: []byte{1}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = conn.Recv()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if disconnected := s.SetMaintenance(Maintenance{Enabled: true, KeepSessions: true}); disconnected != 0 {
+		t.Errorf("disconnected %d kept sessions", disconnected)
+	}
+
+	received := make(chan Packet, 1)
+	go func() {
+		packet, _ := conn.Recv()
+		received <- packet
+	}()
+
+	if disconnected := s.SetMaintenance(Maintenance{Enabled: true, Message: "bye"}); disconnected != 1 {
+		t.Errorf("disconnected %d sessions", disconnected)
+	}
+
+	notification, ok := (<-received).(*DisconnectionNotification)
+	if !ok || notification.Notification != "bye" {
+		t.Errorf("unexpected notification %v", notification)
+	}
+}
@@ -0,0 +1,62 @@
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/metrics"
+)
+
+const (
+	handshakeStageTLS         = "tls"
+	handshakeStageDeadline    = "deadline"
+	handshakeStageAuth        = "auth"
+	handshakeStageMaintenance = "maintenance"
+
+	unknownPacketLabel = "unknown"
+)
+
+var (
+	activeConnections = metrics.NewGauge(
+		"ddda_game_connections_active",
+		"Game clients that are currently connected.")
+	handshakeFailures = metrics.NewCounterVec(
+		"ddda_game_handshake_failures_total",
+		"Connections that failed before the session started, by stage.",
+		"stage")
+	redirectsTotal = metrics.NewCounterVec(
+		"ddda_game_redirects_total",
+		"Clients that were sent to another server after authentication, by target.",
+		"target")
+	requestsTotal = metrics.NewCounterVec(
+		"ddda_game_requests_total",
+		"Handled requests by packet and the error id of the answer.",
+		"packet", "error_id")
+	requestDuration = metrics.NewHistogramVec(
+		"ddda_game_request_duration_seconds",
+		"Time spent handling a request, by packet.",
+		metrics.DefaultBuckets,
+		"packet")
+)
+
+// serverRequestObserver records every dispatched request in the package metrics.
+type serverRequestObserver struct{}
+
+func (serverRequestObserver) ObserveRequest(packetType PacketType, duration time.Duration, err error) {
+	// Unregistered packets share one label so clients cannot create a series per name id.
+	packet, ok := packets.name(packetType.NameID)
+	if !ok {
+		packet = unknownPacketLabel
+	}
+
+	errorID := noErrorID
+	if err != nil && err != ErrClientDisconnected {
+		errorID = unknownErrorID
+		if reqErr, ok := err.(*RequestError); ok {
+			errorID = reqErr.ErrorID
+		}
+	}
+
+	requestsTotal.With(packet, fmt.Sprintf("0x%02x", uint8(errorID))).Inc()
+	requestDuration.With(packet).Observe(duration.Seconds())
+}
@@ -0,0 +1,133 @@
+package network
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// LoggingMiddleware logs every request with its duration and outcome.
+func LoggingMiddleware(next Handler) Handler {
+	return HandlerFunc(func(client *ClientConn, request Packet) error {
+		start := time.Now()
+		err := next.ServePacket(client, request)
+		packetType := GetPacketType(request)
+
+		log := client.Logger().WithFields(packetLogFields(packetType)).WithField("duration", time.Since(start))
+		if err != nil && err != ErrClientDisconnected {
+			log.Warnf("%v failed: %v", &packetType, err)
+		} else {
+			log.Infof("%v handled", &packetType)
+		}
+
+		return err
+	})
+}
+
+// AuthorizationMiddleware answers every request that authorize rejects with a not authorized error response.
+func AuthorizationMiddleware(authorize func(client *ClientConn, request Packet) error) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(client *ClientConn, request Packet) error {
+			err := authorize(client, request)
+			if err != nil {
+				return NewRequestError(request, notAuthorizedErrorID, err)
+			}
+
+			return next.ServePacket(client, request)
+		})
+	}
+}
+
+// RateLimitMiddleware allows each connection a burst of requests that refills at the given rate per second.
+func RateLimitMiddleware(rate float64, burst int) Middleware {
+	limiter := &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[*ClientConn]*tokenBucket),
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(client *ClientConn, request Packet) error {
+			if !limiter.Allow(client, time.Now()) {
+				return NewRequestError(request, rateLimitedErrorID, errors.New("rate limit exceeded"))
+			}
+
+			return next.ServePacket(client, request)
+		})
+	}
+}
+
+const minRateLimitSweepSize = 64
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mutex     sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[*ClientConn]*tokenBucket
+	sweepSize int
+}
+
+func (l *rateLimiter) Allow(client *ClientConn, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		l.sweep(now)
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+// sweep drops the buckets that have refilled completely because they are
+// indistinguishable from new ones. Connections are not tracked otherwise.
+func (l *rateLimiter) sweep(now time.Time) {
+	if len(l.buckets) < l.sweepSize {
+		return
+	}
+
+	for client, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+
+	l.sweepSize = 2 * len(l.buckets)
+	if l.sweepSize < minRateLimitSweepSize {
+		l.sweepSize = minRateLimitSweepSize
+	}
+}
+
+type RequestObserver interface {
+	ObserveRequest(packetType PacketType, duration time.Duration, err error)
+}
+
+// MetricsMiddleware reports the duration and outcome of every request to the observer.
+func MetricsMiddleware(observer RequestObserver) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(client *ClientConn, request Packet) error {
+			start := time.Now()
+			err := next.ServePacket(client, request)
+			observer.ObserveRequest(GetPacketType(request), time.Since(start), err)
+			return err
+		})
+	}
+}
@@ -34,9 +34,19 @@ const (
 	unknownTypeID  PacketTypeID = 0xFF
 )
 
+// The game's own error codes are unknown, these are the codes this server answers with.
 const (
-	noErrorID      PacketErrorID = 0x00
-	unknownErrorID PacketErrorID = 0xFF
+	noErrorID                   PacketErrorID = 0x00
+	invalidPropertyIndexErrorID PacketErrorID = 0x01
+	invalidUserErrorID          PacketErrorID = 0x02
+	userAreaSizeErrorID         PacketErrorID = 0x03
+	invalidUserAreaErrorID      PacketErrorID = 0x04
+	storageErrorID              PacketErrorID = 0x05
+	notAuthorizedErrorID        PacketErrorID = 0x06
+	invalidRequestErrorID       PacketErrorID = 0x07
+	rateLimitedErrorID          PacketErrorID = 0x08
+	unknownPacketErrorID        PacketErrorID = 0x09
+	unknownErrorID              PacketErrorID = 0xFF
 )
 
 const (
@@ -55,6 +65,33 @@ type PacketTypeID uint8
 
 type PacketErrorID uint8
 
+func (id PacketErrorID) String() string {
+	switch id {
+	case noErrorID:
+		return "no error"
+	case invalidPropertyIndexErrorID:
+		return "invalid property index"
+	case invalidUserErrorID:
+		return "invalid user id"
+	case userAreaSizeErrorID:
+		return "user area size exceeded"
+	case invalidUserAreaErrorID:
+		return "invalid user area"
+	case storageErrorID:
+		return "storage failure"
+	case notAuthorizedErrorID:
+		return "not authorized"
+	case invalidRequestErrorID:
+		return "invalid request"
+	case rateLimitedErrorID:
+		return "rate limited"
+	case unknownPacketErrorID:
+		return "unknown packet"
+	default:
+		return "unknown error"
+	}
+}
+
 type PacketType struct {
 	NameID  PacketNameID
 	TypeID  PacketTypeID
@@ -575,6 +612,27 @@ type TusUserAreaReadResponseFooter struct {
 	EmptyPacket
 }
 
+type ErrorResponse struct {
+	PacketHeader
+	Data []byte
+}
+
+func (p *ErrorResponse) Payload() ([]byte, error) {
+	return p.Data, nil
+}
+
+func (p *ErrorResponse) SetPayload(payload []byte) error {
+	p.Data = make([]byte, len(payload))
+	copy(p.Data, payload)
+	return nil
+}
+
+func NewErrorResponse(nameID PacketNameID, errorID PacketErrorID) *ErrorResponse {
+	var p ErrorResponse
+	p.PacketType = PacketType{nameID, responseID, errorID}
+	return &p
+}
+
 // notifications
 
 type DisconnectionNotification struct {
@@ -687,94 +745,44 @@ func (p *ConnectionSummaryNotification) SetPayload(payload []byte) error {
 	return nil
 }
 
-var (
-	packetGenerator map[PacketTypeID]map[PacketNameID]func() Packet
-)
-
 func init() {
-	packetGenerator = map[PacketTypeID]map[PacketNameID]func() Packet{
-		requestID: {
-			onlineCheckID:                     func() Packet { return &OnlineCheckRequest{} },
-			disconnectionID:                   func() Packet { return &DisconnectionRequest{} },
-			fastDataID:                        func() Packet { return &FastDataRequest{} },
-			authenticationInformationHeaderID: func() Packet { return &AuthenticationInformationRequestHeader{} },
-			authenticationInformationDataID:   func() Packet { return &AuthenticationInformationRequestData{} },
-			authenticationInformationFooterID: func() Packet { return &AuthenticationInformationRequestFooter{} },
-			tusCommonAreaAcquisitionID:        func() Packet { return &TusCommonAreaAcquisitionRequest{} },
-			tusCommonAreaSettingsID:           func() Packet { return &TusCommonAreaSettingsRequest{} },
-			tusCommonAreaAddID:                func() Packet { return &TusCommonAreaAddRequest{} },
-			tusUserAreaWriteHeaderID:          func() Packet { return &TusUserAreaWriteRequestHeader{} },
-			tusUserAreaWriteDataID:            func() Packet { return &TusUserAreaWriteRequestData{} },
-			tusUserAreaWriteFooterID:          func() Packet { return &TusUserAreaWriteRequestFooter{} },
-			tusUserAreaReadHeaderID:           func() Packet { return &TusUserAreaReadRequestHeader{} },
-			tusUserAreaReadDataID:             func() Packet { return &TusUserAreaReadRequestData{} },
-			tusUserAreaReadFooterID:           func() Packet { return &TusUserAreaReadRequestFooter{} },
-		},
-		responseID: {
-			onlineCheckID:                     func() Packet { return &OnlineCheckResponse{} },
-			disconnectionID:                   func() Packet { return &DisconnectionResponse{} },
-			fastDataID:                        func() Packet { return &FastDataResponse{} },
-			authenticationInformationHeaderID: func() Packet { return &AuthenticationInformationResponseHeader{} },
-			authenticationInformationDataID:   func() Packet { return &AuthenticationInformationResponseData{} },
-			authenticationInformationFooterID: func() Packet { return &AuthenticationInformationResponseFooter{} },
-			tusCommonAreaAcquisitionID:        func() Packet { return &TusCommonAreaAcquisitionResponse{} },
-			tusCommonAreaSettingsID:           func() Packet { return &TusCommonAreaSettingsResponse{} },
-			tusCommonAreaAddID:                func() Packet { return &TusCommonAreaAddResponse{} },
-			tusUserAreaWriteHeaderID:          func() Packet { return &TusUserAreaWriteResponseHeader{} },
-			tusUserAreaWriteDataID:            func() Packet { return &TusUserAreaWriteResponseData{} },
-			tusUserAreaWriteFooterID:          func() Packet { return &TusUserAreaWriteResponseFooter{} },
-			tusUserAreaReadHeaderID:           func() Packet { return &TusUserAreaReadResponseHeader{} },
-			tusUserAreaReadDataID:             func() Packet { return &TusUserAreaReadResponseData{} },
-			tusUserAreaReadFooterID:           func() Packet { return &TusUserAreaReadResponseFooter{} },
-		},
-		notificationID: {
-			disconnectionID:     func() Packet { return &DisconnectionNotification{} },
-			reconnectionID:      func() Packet { return &ReconnectionNotification{} },
-			connectionSummaryID: func() Packet { return &ConnectionSummaryNotification{} },
-		},
-	}
+	mustRegisterPacket(onlineCheckID, requestID, "onlineCheck", func() Packet { return &OnlineCheckRequest{} })
+	mustRegisterPacket(onlineCheckID, responseID, "onlineCheck", func() Packet { return &OnlineCheckResponse{} })
+	mustRegisterPacket(disconnectionID, requestID, "disconnection", func() Packet { return &DisconnectionRequest{} })
+	mustRegisterPacket(disconnectionID, responseID, "disconnection", func() Packet { return &DisconnectionResponse{} })
+	mustRegisterPacket(disconnectionID, notificationID, "disconnection", func() Packet { return &DisconnectionNotification{} })
+	mustRegisterPacket(reconnectionID, notificationID, "reconnection", func() Packet { return &ReconnectionNotification{} })
+	mustRegisterPacket(fastDataID, requestID, "fastData", func() Packet { return &FastDataRequest{} })
+	mustRegisterPacket(fastDataID, responseID, "fastData", func() Packet { return &FastDataResponse{} })
+	mustRegisterPacket(connectionSummaryID, notificationID, "connectionSummary", func() Packet { return &ConnectionSummaryNotification{} })
+	mustRegisterPacket(authenticationInformationHeaderID, requestID, "authenticationInformationHeader", func() Packet { return &AuthenticationInformationRequestHeader{} })
+	mustRegisterPacket(authenticationInformationHeaderID, responseID, "authenticationInformationHeader", func() Packet { return &AuthenticationInformationResponseHeader{} })
+	mustRegisterPacket(authenticationInformationDataID, requestID, "authenticationInformationData", func() Packet { return &AuthenticationInformationRequestData{} })
+	mustRegisterPacket(authenticationInformationDataID, responseID, "authenticationInformationData", func() Packet { return &AuthenticationInformationResponseData{} })
+	mustRegisterPacket(authenticationInformationFooterID, requestID, "authenticationInformationFooter", func() Packet { return &AuthenticationInformationRequestFooter{} })
+	mustRegisterPacket(authenticationInformationFooterID, responseID, "authenticationInformationFooter", func() Packet { return &AuthenticationInformationResponseFooter{} })
+	mustRegisterPacket(tusCommonAreaAcquisitionID, requestID, "tusCommonAreaAcquisition", func() Packet { return &TusCommonAreaAcquisitionRequest{} })
+	mustRegisterPacket(tusCommonAreaAcquisitionID, responseID, "tusCommonAreaAcquisition", func() Packet { return &TusCommonAreaAcquisitionResponse{} })
+	mustRegisterPacket(tusCommonAreaSettingsID, requestID, "tusCommonAreaSettings", func() Packet { return &TusCommonAreaSettingsRequest{} })
+	mustRegisterPacket(tusCommonAreaSettingsID, responseID, "tusCommonAreaSettings", func() Packet { return &TusCommonAreaSettingsResponse{} })
+	mustRegisterPacket(tusCommonAreaAddID, requestID, "tusCommonAreaAdd", func() Packet { return &TusCommonAreaAddRequest{} })
+	mustRegisterPacket(tusCommonAreaAddID, responseID, "tusCommonAreaAdd", func() Packet { return &TusCommonAreaAddResponse{} })
+	mustRegisterPacket(tusUserAreaWriteHeaderID, requestID, "tusUserAreaWriteHeader", func() Packet { return &TusUserAreaWriteRequestHeader{} })
+	mustRegisterPacket(tusUserAreaWriteHeaderID, responseID, "tusUserAreaWriteHeader", func() Packet { return &TusUserAreaWriteResponseHeader{} })
+	mustRegisterPacket(tusUserAreaWriteDataID, requestID, "tusUserAreaWriteData", func() Packet { return &TusUserAreaWriteRequestData{} })
+	mustRegisterPacket(tusUserAreaWriteDataID, responseID, "tusUserAreaWriteData", func() Packet { return &TusUserAreaWriteResponseData{} })
+	mustRegisterPacket(tusUserAreaWriteFooterID, requestID, "tusUserAreaWriteFooter", func() Packet { return &TusUserAreaWriteRequestFooter{} })
+	mustRegisterPacket(tusUserAreaWriteFooterID, responseID, "tusUserAreaWriteFooter", func() Packet { return &TusUserAreaWriteResponseFooter{} })
+	mustRegisterPacket(tusUserAreaReadHeaderID, requestID, "tusUserAreaReadHeader", func() Packet { return &TusUserAreaReadRequestHeader{} })
+	mustRegisterPacket(tusUserAreaReadHeaderID, responseID, "tusUserAreaReadHeader", func() Packet { return &TusUserAreaReadResponseHeader{} })
+	mustRegisterPacket(tusUserAreaReadDataID, requestID, "tusUserAreaReadData", func() Packet { return &TusUserAreaReadRequestData{} })
+	mustRegisterPacket(tusUserAreaReadDataID, responseID, "tusUserAreaReadData", func() Packet { return &TusUserAreaReadResponseData{} })
+	mustRegisterPacket(tusUserAreaReadFooterID, requestID, "tusUserAreaReadFooter", func() Packet { return &TusUserAreaReadRequestFooter{} })
+	mustRegisterPacket(tusUserAreaReadFooterID, responseID, "tusUserAreaReadFooter", func() Packet { return &TusUserAreaReadResponseFooter{} })
 }
 
 func (pt *PacketType) String() string {
-	var n string
-	switch pt.NameID {
-	case onlineCheckID:
-		n = "onlineCheck"
-	case disconnectionID:
-		n = "disconnection"
-	case reconnectionID:
-		n = "reconnection"
-	case fastDataID:
-		n = "fastData"
-	case connectionSummaryID:
-		n = "connectionSummary"
-	case authenticationInformationHeaderID:
-		n = "authenticationInformationHeader"
-	case authenticationInformationDataID:
-		n = "authenticationInformationData"
-	case authenticationInformationFooterID:
-		n = "authenticationInformationFooter"
-	case tusCommonAreaAcquisitionID:
-		n = "tusCommonAreaAcquisition"
-	case tusCommonAreaSettingsID:
-		n = "tusCommonAreaSettings"
-	case tusCommonAreaAddID:
-		n = "tusCommonAreaAdd"
-	case tusUserAreaWriteHeaderID:
-		n = "tusUserAreaWriteHeader"
-	case tusUserAreaWriteDataID:
-		n = "tusUserAreaWriteData"
-	case tusUserAreaWriteFooterID:
-		n = "tusUserAreaWriteFooter"
-	case tusUserAreaReadHeaderID:
-		n = "tusUserAreaReadHeader"
-	case tusUserAreaReadDataID:
-		n = "tusUserAreaReadData"
-	case tusUserAreaReadFooterID:
-		n = "tusUserAreaReadFooter"
-	default:
-		n = fmt.Sprintf("unknown(%x)", pt.NameID)
-	}
+	n := packetName(pt.NameID)
 
 	var t string
 	switch pt.TypeID {
@@ -793,97 +801,55 @@ func (pt *PacketType) String() string {
 	case noErrorID:
 		e = ""
 	default:
-		e = fmt.Sprintf(" error(%x)", pt.ErrorID)
+		e = fmt.Sprintf(" error(%x: %v)", uint8(pt.ErrorID), pt.ErrorID)
 	}
 
 	return fmt.Sprintf("%s %s%s", n, t, e)
 }
 
+func packetName(nameID PacketNameID) string {
+	name, ok := packets.name(nameID)
+	if !ok {
+		return fmt.Sprintf("unknown(%x)", nameID)
+	}
+
+	return name
+}
+
 func GetPacketType(p Packet) PacketType {
-	switch p.(type) {
-	case *OnlineCheckRequest:
-		return PacketType{onlineCheckID, requestID, noErrorID}
-	case *DisconnectionRequest:
-		return PacketType{disconnectionID, requestID, noErrorID}
-	case *FastDataRequest:
-		return PacketType{fastDataID, requestID, noErrorID}
-	case *AuthenticationInformationRequestHeader:
-		return PacketType{authenticationInformationHeaderID, requestID, noErrorID}
-	case *AuthenticationInformationRequestData:
-		return PacketType{authenticationInformationDataID, requestID, noErrorID}
-	case *AuthenticationInformationRequestFooter:
-		return PacketType{authenticationInformationFooterID, requestID, noErrorID}
-	case *TusCommonAreaAcquisitionRequest:
-		return PacketType{tusCommonAreaAcquisitionID, requestID, noErrorID}
-	case *TusCommonAreaSettingsRequest:
-		return PacketType{tusCommonAreaSettingsID, requestID, noErrorID}
-	case *TusCommonAreaAddRequest:
-		return PacketType{tusCommonAreaAddID, requestID, noErrorID}
-	case *TusUserAreaWriteRequestHeader:
-		return PacketType{tusUserAreaWriteHeaderID, requestID, noErrorID}
-	case *TusUserAreaWriteRequestData:
-		return PacketType{tusUserAreaWriteDataID, requestID, noErrorID}
-	case *TusUserAreaWriteRequestFooter:
-		return PacketType{tusUserAreaWriteFooterID, requestID, noErrorID}
-	case *TusUserAreaReadRequestHeader:
-		return PacketType{tusUserAreaReadHeaderID, requestID, noErrorID}
-	case *TusUserAreaReadRequestData:
-		return PacketType{tusUserAreaReadDataID, requestID, noErrorID}
-	case *TusUserAreaReadRequestFooter:
-		return PacketType{tusUserAreaReadFooterID, requestID, noErrorID}
-	case *OnlineCheckResponse:
-		return PacketType{onlineCheckID, responseID, noErrorID}
-	case *DisconnectionResponse:
-		return PacketType{disconnectionID, responseID, noErrorID}
-	case *FastDataResponse:
-		return PacketType{fastDataID, responseID, noErrorID}
-	case *AuthenticationInformationResponseHeader:
-		return PacketType{authenticationInformationHeaderID, responseID, noErrorID}
-	case *AuthenticationInformationResponseData:
-		return PacketType{authenticationInformationDataID, responseID, noErrorID}
-	case *AuthenticationInformationResponseFooter:
-		return PacketType{authenticationInformationFooterID, responseID, noErrorID}
-	case *TusCommonAreaAcquisitionResponse:
-		return PacketType{tusCommonAreaAcquisitionID, responseID, noErrorID}
-	case *TusCommonAreaSettingsResponse:
-		return PacketType{tusCommonAreaSettingsID, responseID, noErrorID}
-	case *TusCommonAreaAddResponse:
-		return PacketType{tusCommonAreaAddID, responseID, noErrorID}
-	case *TusUserAreaWriteResponseHeader:
-		return PacketType{tusUserAreaWriteHeaderID, responseID, noErrorID}
-	case *TusUserAreaWriteResponseData:
-		return PacketType{tusUserAreaWriteDataID, responseID, noErrorID}
-	case *TusUserAreaWriteResponseFooter:
-		return PacketType{tusUserAreaWriteFooterID, responseID, noErrorID}
-	case *TusUserAreaReadResponseHeader:
-		return PacketType{tusUserAreaReadHeaderID, responseID, noErrorID}
-	case *TusUserAreaReadResponseData:
-		return PacketType{tusUserAreaReadDataID, responseID, noErrorID}
-	case *TusUserAreaReadResponseFooter:
-		return PacketType{tusUserAreaReadFooterID, responseID, noErrorID}
-	case *DisconnectionNotification:
-		return PacketType{disconnectionID, notificationID, noErrorID}
-	case *ReconnectionNotification:
-		return PacketType{reconnectionID, notificationID, noErrorID}
-	case *ConnectionSummaryNotification:
-		return PacketType{connectionSummaryID, notificationID, noErrorID}
-	default:
+	switch p := p.(type) {
+	case *ErrorResponse:
+		return p.PacketType
+	case *UnknownPacket:
+		return p.PacketType
+	}
+
+	registration, ok := packets.lookupValue(p)
+	if !ok {
 		return PacketType{unknownNameID, unknownTypeID, unknownErrorID}
 	}
+
+	return registration.PacketType
 }
 
 func NewPacketFromHeader(header PacketHeader) (Packet, error) {
-	packetTypeMap, ok := packetGenerator[header.PacketType.TypeID]
-	if !ok {
-		return nil, fmt.Errorf("unknwon packet type message type: %x", header.PacketType.TypeID)
+	if header.PacketType.TypeID == responseID && header.PacketType.ErrorID != noErrorID {
+		packet := &ErrorResponse{}
+		packet.SetHeader(header)
+		return packet, nil
 	}
 
-	packetFunc, ok := packetTypeMap[header.PacketType.NameID]
+	packetType := header.PacketType
+	packetType.ErrorID = noErrorID
+
+	registration, ok := packets.lookupType(packetType)
 	if !ok {
-		return nil, fmt.Errorf("unknown packet type: %x", header.PacketType.NameID)
+		packet := &UnknownPacket{}
+		packet.SetHeader(header)
+		return packet, nil
 	}
 
-	packet := packetFunc()
+	packet := registration.New()
 	packet.SetHeader(header)
 	return packet, nil
 }
@@ -917,6 +883,52 @@ func NewPacketTypeError(expected interface{}, actual interface{}) PacketTypeErro
 	}
 }
 
+type ProtocolError struct {
+	NameID   PacketNameID
+	ErrorID  PacketErrorID
+	Meaning  string
+	Response *ErrorResponse
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s request refused: %s (error %x)", packetName(e.NameID), e.Meaning, uint8(e.ErrorID))
+}
+
+func NewProtocolError(response *ErrorResponse) *ProtocolError {
+	return &ProtocolError{
+		NameID:   response.PacketType.NameID,
+		ErrorID:  response.PacketType.ErrorID,
+		Meaning:  response.PacketType.ErrorID.String(),
+		Response: response,
+	}
+}
+
+type RequestError struct {
+	PacketType  PacketType
+	ErrorID     PacketErrorID
+	Err         error
+	Recoverable bool
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%v: %v", e.ErrorID, e.Err)
+}
+
+func NewRequestError(request Packet, errorID PacketErrorID, err error) *RequestError {
+	return &RequestError{
+		PacketType:  GetPacketType(request),
+		ErrorID:     errorID,
+		Err:         err,
+		Recoverable: true,
+	}
+}
+
+func NewFatalRequestError(request Packet, errorID PacketErrorID, err error) *RequestError {
+	reqErr := NewRequestError(request, errorID, err)
+	reqErr.Recoverable = false
+	return reqErr
+}
+
 func readDynamicString(data []byte) (s string, n int, err error) {
 	b, n, err := readDynamicData(data[:])
 	if err != nil {
@@ -0,0 +1,215 @@
+package network
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+)
+
+type ProxyConfig struct {
+	Port         int
+	CertFile     string
+	KeyFile      string
+	UpstreamHost string
+	UpstreamPort int
+	CaptureFile  string
+	Rewriters    []PacketRewriter
+	Logger       *logging.Logger
+}
+
+// PacketRewriter can replace a forwarded packet. Returning a nil packet drops it.
+type PacketRewriter func(direction CaptureDirection, packet Packet) (Packet, error)
+
+// RewriteUser replaces the user that clients log in with, for example to log into the official server with another account.
+func RewriteUser(user string) PacketRewriter {
+	return func(direction CaptureDirection, packet Packet) (Packet, error) {
+		if fastDataResponse, ok := packet.(*FastDataResponse); ok && direction == ClientToServer {
+			fastDataResponse.User = user
+		}
+
+		return packet, nil
+	}
+}
+
+// RewriteReconnection keeps clients on the proxy when the upstream server redirects them.
+func RewriteReconnection(host string, port uint16) PacketRewriter {
+	return func(direction CaptureDirection, packet Packet) (Packet, error) {
+		if reconnectionNotification, ok := packet.(*ReconnectionNotification); ok && direction == ServerToClient {
+			reconnectionNotification.Host = host
+			reconnectionNotification.Port = port
+		}
+
+		return packet, nil
+	}
+}
+
+// Proxy accepts game clients and forwards every packet to an upstream server.
+// Each side keeps its own sequence ids because both connections are numbered independently.
+type Proxy struct {
+	config    ProxyConfig
+	tlsConfig *tls.Config
+	capture   *CaptureWriter
+	listener  net.Listener
+	log       *logging.Logger
+	connID    int64
+	closed    int32
+}
+
+func NewProxy(cfg ProxyConfig) (*Proxy, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		config: cfg,
+		log:    cfg.Logger.WithField("component", "proxy"),
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{
+				cert,
+			},
+			MaxVersion: tls.VersionTLS10,
+		},
+	}
+
+	if len(cfg.CaptureFile) > 0 {
+		p.capture, err = OpenCaptureFile(cfg.CaptureFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Proxy) ListenAndServe() error {
+	listener, err := tls.Listen("tcp", fmt.Sprintf(":%d", p.config.Port), p.tlsConfig)
+	if err != nil {
+		return err
+	}
+	p.listener = listener
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&p.closed) != 0 {
+				return nil
+			}
+
+			p.log.Warnf("accept failed: %v", err)
+			continue
+		}
+
+		go p.handleConnection(conn)
+	}
+}
+
+func (p *Proxy) Close() error {
+	atomic.StoreInt32(&p.closed, 1)
+
+	if p.listener != nil {
+		err := p.listener.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.capture != nil {
+		return p.capture.Close()
+	}
+
+	return nil
+}
+
+func (p *Proxy) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	connID := atomic.AddInt64(&p.connID, 1)
+
+	upstreamConn, err := tls.Dial("tcp", fmt.Sprintf("%s:%d", p.config.UpstreamHost, p.config.UpstreamPort), &tls.Config{})
+	if err != nil {
+		p.log.WithField("conn", connID).Warnf("failed to connect upstream: %v", err)
+		return
+	}
+	defer upstreamConn.Close()
+
+	downstream := NewClientConn(conn, connID, true)
+	downstream.SetCapture(p.capture)
+	downstream.SetLogger(p.log)
+	upstream := NewClientConn(upstreamConn, connID, false)
+	upstream.SetLogger(p.log.WithField("upstream", true))
+
+	log := downstream.Logger()
+	log.Infof("connected to %s", upstreamConn.RemoteAddr())
+
+	err = p.Forward(downstream, upstream)
+	if err != nil {
+		log.Warnf("%v", err)
+	}
+
+	downstream.Logger().Infof("disconnected")
+}
+
+// Forward relays packets between a client and an upstream server connection until either side fails.
+func (p *Proxy) Forward(downstream *ClientConn, upstream *ClientConn) error {
+	errs := make(chan error, 2)
+	var once sync.Once
+	closeBoth := func() {
+		downstream.Close()
+		upstream.Close()
+	}
+
+	go func() {
+		errs <- p.forward(downstream, upstream, ClientToServer)
+		once.Do(closeBoth)
+	}()
+	go func() {
+		errs <- p.forward(upstream, downstream, ServerToClient)
+		once.Do(closeBoth)
+	}()
+
+	err := <-errs
+	<-errs
+	return err
+}
+
+func (p *Proxy) forward(from *ClientConn, to *ClientConn, direction CaptureDirection) error {
+	for {
+		packet, err := from.Recv()
+		if protocolErr, ok := err.(*ProtocolError); ok {
+			packet, err = protocolErr.Response, nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", direction, err)
+		}
+
+		packetType := GetPacketType(packet)
+		for _, rewrite := range p.config.Rewriters {
+			packet, err = rewrite(direction, packet)
+			if err != nil {
+				return fmt.Errorf("failed to rewrite %v: %v", &packetType, err)
+			}
+
+			if packet == nil {
+				break
+			}
+		}
+
+		log := from.Logger().WithFields(packetLogFields(packetType)).WithField("direction", direction)
+		if packet == nil {
+			log.Infof("dropped %v", &packetType)
+			continue
+		}
+
+		log.Infof("%v", &packetType)
+
+		err = to.Send(packet)
+		if err != nil {
+			return fmt.Errorf("%v: %v", direction, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+type userRecorder struct {
+	user string
+}
+
+func (v *userRecorder) VerifyToken(user string, token []byte) (*Identity, error) {
+	v.user = user
+	return &Identity{User: user}, nil
+}
+
+func TestProxyForward(t *testing.T) {
+	verifier := &userRecorder{}
+	server := NewInProcessServer(ServerConfig{TokenVerifier: verifier}, newMemoryDatabase())
+	serverSide, upstreamSide := net.Pipe()
+	go server.ServeConn(serverSide)
+
+	proxySide, clientSide := net.Pipe()
+	proxy := &Proxy{config: ProxyConfig{Rewriters: []PacketRewriter{RewriteUser("0110000100000002")}}}
+	forwardErrs := make(chan error, 1)
+	go func() {
+		forwardErrs <- proxy.Forward(NewClientConn(proxySide, 1, true), NewClientConn(upstreamSide, 1, false))
+	}()
+
+	client := &Client{
+		cfg:  ClientConfig{User: "0110000100000001", UserToken: []byte{1, 2, 3}},
+		conn: NewClientConn(clientSide, 0, false),
+	}
+
+	err := client.authenticate()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if verifier.user != "0110000100000002" {
+		t.Errorf("User was not rewritten: %s", verifier.user)
+	}
+
+	dragon, err := client.GetOnlineUrDragon(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if dragon.Generation != 1 {
+		t.Errorf("unexpected generation %d", dragon.Generation)
+	}
+
+	err = client.Disconnect(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+
+	<-forwardErrs
+}
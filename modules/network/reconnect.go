@@ -0,0 +1,175 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+)
+
+type ClientState int
+
+const (
+	ClientDisconnected ClientState = iota
+	ClientConnecting
+	ClientConnected
+	// ClientReconnecting is reported while a resilient client waits before its next connection attempt.
+	ClientReconnecting
+)
+
+func (s ClientState) String() string {
+	switch s {
+	case ClientDisconnected:
+		return "disconnected"
+	case ClientConnecting:
+		return "connecting"
+	case ClientConnected:
+		return "connected"
+	case ClientReconnecting:
+		return "reconnecting"
+	default:
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+}
+
+const (
+	defaultReconnectInitial = 500 * time.Millisecond
+	defaultReconnectMax     = 30 * time.Second
+	maxRequestRetries       = 3
+	maxRedirects            = 5
+)
+
+// ReconnectBackoff doubles the wait between connection attempts of a resilient client, starting at Initial
+// and capped at Max. Zero durations use the defaults. Zero Attempts keeps trying until the context ends.
+type ReconnectBackoff struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Attempts int
+}
+
+func (b ReconnectBackoff) delay(failures int) time.Duration {
+	initial, max := b.Initial, b.Max
+	if initial <= 0 {
+		initial = defaultReconnectInitial
+	}
+	if max <= 0 {
+		max = defaultReconnectMax
+	}
+
+	delay := initial
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	return delay
+}
+
+// RedirectError is returned when the server asks the client to reconnect to another server.
+// The client connects there the next time.
+type RedirectError struct {
+	Host string
+	Port uint16
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("redirected to %s:%d", e.Host, e.Port)
+}
+
+func (c *Client) setState(state ClientState, err error) {
+	if c.state == state && err == nil {
+		return
+	}
+	c.state = state
+
+	if c.cfg.OnStateChange != nil {
+		c.cfg.OnStateChange(state, err)
+	}
+}
+
+// followRedirect points the client to the server of a reconnection notification and reports it as a RedirectError.
+func (c *Client) followRedirect(packet Packet) (Packet, error) {
+	notification, ok := packet.(*ReconnectionNotification)
+	if !ok {
+		return packet, nil
+	}
+
+	c.conn.Logger().Infof("redirected to %s:%d", notification.Host, notification.Port)
+	c.cfg.Host = notification.Host
+	c.cfg.Port = int(notification.Port)
+
+	return nil, &RedirectError{Host: notification.Host, Port: notification.Port}
+}
+
+// reconnect connects until it succeeds, the server rejects the token, the attempts are used up or the context ends.
+// Redirects are followed right away, other failures back off. The caller holds the mutex.
+func (c *Client) reconnect(ctx context.Context) error {
+	failures, redirects := 0, 0
+	for {
+		err := c.connect(ctx)
+		if err == nil || err == ErrTokenRejected || err == ctx.Err() {
+			return err
+		}
+
+		if _, ok := err.(*RedirectError); ok && redirects < maxRedirects {
+			redirects++
+			continue
+		}
+
+		failures++
+		if c.cfg.Backoff.Attempts > 0 && failures >= c.cfg.Backoff.Attempts {
+			return err
+		}
+
+		delay := c.cfg.Backoff.delay(failures)
+		c.cfg.Logger.WithFields(logging.Fields{"host": c.cfg.Host, "port": c.cfg.Port}).Warnf("connection failed, retrying in %v: %v", delay, err)
+		c.setState(ClientReconnecting, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.setState(ClientDisconnected, ctx.Err())
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// doResilient connects before the exchange if needed. An idempotent exchange that fails because the connection
+// broke or the server redirected is retried on a new connection. The caller holds the mutex.
+func (c *Client) doResilient(ctx context.Context, idempotent bool, exchange func() error) error {
+	for retries := 0; ; retries++ {
+		if c.conn == nil {
+			err := c.reconnect(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := c.exchange(ctx, exchange)
+		if !isConnectionFailure(err) {
+			return err
+		}
+
+		c.close(err)
+		if !idempotent || retries >= maxRequestRetries {
+			return err
+		}
+
+		c.cfg.Logger.Debugf("retrying after %v", err)
+	}
+}
+
+// isConnectionFailure tells errors that leave the connection unusable from error responses and cancellation.
+func isConnectionFailure(err error) bool {
+	switch err.(type) {
+	case nil, *ProtocolError:
+		return false
+	}
+
+	return err != context.Canceled && err != context.DeadlineExceeded
+}
@@ -0,0 +1,182 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serverDialer serves every dialed address with the in-process server registered for it.
+// The connections go over loopback TCP because a server may write before it reads, which a net.Pipe can not buffer.
+func serverDialer(servers map[string]*Server, dialErrs *int) func(ctx context.Context, address string) (net.Conn, error) {
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		if dialErrs != nil && *dialErrs > 0 {
+			*dialErrs--
+			return nil, errors.New("connection refused")
+		}
+
+		s, ok := servers[address]
+		if !ok {
+			return nil, errors.New("unknown host")
+		}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer listener.Close()
+
+		clientSide, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+
+		serverSide, err := listener.Accept()
+		if err != nil {
+			clientSide.Close()
+			return nil, err
+		}
+
+		go s.ServeConn(serverSide)
+		return clientSide, nil
+	}
+}
+
+type stateRecorder struct {
+	states []ClientState
+}
+
+func (r *stateRecorder) record(state ClientState, err error) {
+	r.states = append(r.states, state)
+}
+
+func (r *stateRecorder) count(state ClientState) int {
+	n := 0
+	for _, s := range r.states {
+		if s == state {
+			n++
+		}
+	}
+	return n
+}
+
+func TestResilientClientFollowsRedirect(t *testing.T) {
+	serverA := NewInProcessServer(ServerConfig{}, newMemoryDatabase())
+	serverA.HandleFunc(tusCommonAreaAcquisitionID, func(client *ClientConn, request Packet) error {
+		err := client.Send(&ReconnectionNotification{Host: "b", Port: 2})
+		if err != nil {
+			return err
+		}
+
+		return ErrClientDisconnected
+	})
+	serverB := NewInProcessServer(ServerConfig{}, newMemoryDatabase())
+
+	states := &stateRecorder{}
+	c := NewClient(ClientConfig{Host: "a", Port: 1, User: "0110000100000001", Resilient: true, OnStateChange: states.record})
+	c.cfg.Dial = serverDialer(map[string]*Server{"a:1": serverA, "b:2": serverB}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dragon, err := c.GetOnlineUrDragon(ctx)
+	if err != nil {
+		t.Errorf("redirected call failed: %v", err)
+		return
+	}
+
+	if dragon.Generation != 1 || c.cfg.Host != "b" || c.cfg.Port != 2 {
+		t.Errorf("unexpected dragon %d or server %s:%d", dragon.Generation, c.cfg.Host, c.cfg.Port)
+	}
+
+	if states.count(ClientConnected) != 2 || states.count(ClientDisconnected) != 1 {
+		t.Errorf("unexpected states %v", states.states)
+	}
+
+	err = c.Disconnect(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResilientClientReconnects(t *testing.T) {
+	var requests int32
+	s := NewInProcessServer(ServerConfig{}, newMemoryDatabase())
+	s.HandleFunc(tusCommonAreaAddID, func(client *ClientConn, request Packet) error {
+		atomic.AddInt32(&requests, 1)
+		return errors.New("dropped")
+	})
+	s.HandleFunc(tusCommonAreaAcquisitionID, func(client *ClientConn, request Packet) error {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			return errors.New("dropped")
+		}
+
+		return s.handleCommonAreaAcquisition(client, request)
+	})
+
+	dialErrs := 2
+	states := &stateRecorder{}
+	c := NewClient(ClientConfig{
+		Host:          "a",
+		Port:          1,
+		User:          "0110000100000001",
+		Resilient:     true,
+		Backoff:       ReconnectBackoff{Initial: time.Millisecond},
+		OnStateChange: states.record,
+	})
+	c.cfg.Dial = serverDialer(map[string]*Server{"a:1": s}, &dialErrs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := c.Connect(ctx)
+	if err != nil {
+		t.Errorf("connect failed: %v", err)
+		return
+	}
+
+	if states.count(ClientReconnecting) != 2 {
+		t.Errorf("unexpected states %v", states.states)
+	}
+
+	_, err = c.GetOnlineUrDragon(ctx)
+	if err != nil || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("idempotent call was not retried: %d %v", requests, err)
+		return
+	}
+
+	_, err = c.AddOnlineUrDragonProperties(ctx, nil)
+	if err == nil || atomic.LoadInt32(&requests) != 3 {
+		t.Errorf("non-idempotent call was retried: %d %v", requests, err)
+		return
+	}
+
+	err = c.OnlineCheck(ctx)
+	if err != nil {
+		t.Errorf("no reconnect after the failed call: %v", err)
+	}
+}
+
+func TestResilientClientGivesUp(t *testing.T) {
+	dialErrs := 10
+	c := NewClient(ClientConfig{Host: "a", Port: 1, Resilient: true, Backoff: ReconnectBackoff{Initial: time.Millisecond, Attempts: 3}})
+	c.cfg.Dial = serverDialer(nil, &dialErrs)
+
+	err := c.Connect(context.Background())
+	if err == nil || dialErrs != 7 {
+		t.Errorf("unexpected result after %d attempts: %v", 10-dialErrs, err)
+	}
+}
+
+func TestReconnectBackoffDelay(t *testing.T) {
+	b := ReconnectBackoff{Initial: time.Second, Max: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range expected {
+		if actual := b.delay(i + 1); actual != delay {
+			t.Errorf("delay %d: %v != %v", i+1, actual, delay)
+		}
+	}
+}
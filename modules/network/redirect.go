@@ -0,0 +1,133 @@
+package network
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RedirectTarget is a game server that clients are sent to with a ReconnectionNotification.
+type RedirectTarget struct {
+	Host string
+	Port uint16
+}
+
+func (t RedirectTarget) String() string {
+	return net.JoinHostPort(t.Host, strconv.Itoa(int(t.Port)))
+}
+
+// ParseRedirectTarget parses a target in the host:port form.
+func ParseRedirectTarget(target string) (RedirectTarget, error) {
+	host, portArg, err := net.SplitHostPort(target)
+	if err != nil {
+		return RedirectTarget{}, err
+	}
+
+	port, err := strconv.ParseUint(portArg, 10, 16)
+	if err != nil || port == 0 || len(host) == 0 {
+		return RedirectTarget{}, fmt.Errorf("invalid redirect target %s", target)
+	}
+
+	return RedirectTarget{Host: host, Port: uint16(port)}, nil
+}
+
+// RedirectPolicy picks the server that an authenticated client is sent to.
+// Returning false keeps the client on this server.
+type RedirectPolicy interface {
+	Redirect(client *ClientConn) (RedirectTarget, bool)
+}
+
+type RedirectPolicyFunc func(client *ClientConn) (RedirectTarget, bool)
+
+func (f RedirectPolicyFunc) Redirect(client *ClientConn) (RedirectTarget, bool) {
+	return f(client)
+}
+
+// StaticRedirect sends every client to the same server, for example while migrating to a new instance.
+func StaticRedirect(target RedirectTarget) RedirectPolicy {
+	return RedirectPolicyFunc(func(client *ClientConn) (RedirectTarget, bool) {
+		return target, true
+	})
+}
+
+// RoundRobinRedirect spreads the clients evenly across the targets.
+func RoundRobinRedirect(targets []RedirectTarget) RedirectPolicy {
+	var next uint32
+	return RedirectPolicyFunc(func(client *ClientConn) (RedirectTarget, bool) {
+		if len(targets) == 0 {
+			return RedirectTarget{}, false
+		}
+
+		i := atomic.AddUint32(&next, 1) - 1
+		return targets[int(i%uint32(len(targets)))], true
+	})
+}
+
+// HashRedirect sends a user to the same target every time as long as the targets do not change.
+func HashRedirect(targets []RedirectTarget) RedirectPolicy {
+	return RedirectPolicyFunc(func(client *ClientConn) (RedirectTarget, bool) {
+		if len(targets) == 0 {
+			return RedirectTarget{}, false
+		}
+
+		hash := fnv.New32a()
+		hash.Write([]byte(client.User))
+		return targets[int(hash.Sum32()%uint32(len(targets)))], true
+	})
+}
+
+// RedirectPolicy returns the policy that new clients are redirected with, which may be nil.
+func (s *Server) RedirectPolicy() RedirectPolicy {
+	s.redirectMutex.RLock()
+	defer s.redirectMutex.RUnlock()
+	return s.redirectPolicy
+}
+
+// SetRedirectPolicy changes where clients are sent after authentication. Nil keeps them on this server.
+// Existing sessions are not affected.
+func (s *Server) SetRedirectPolicy(policy RedirectPolicy) {
+	s.redirectMutex.Lock()
+	s.redirectPolicy = policy
+	s.redirectMutex.Unlock()
+}
+
+// redirectClient sends an authenticated client to the target of the redirect policy.
+// It returns true if the client was redirected and has to be closed.
+func (s *Server) redirectClient(client *ClientConn) bool {
+	policy := s.RedirectPolicy()
+	if policy == nil {
+		return false
+	}
+
+	target, ok := policy.Redirect(client)
+	if !ok {
+		return false
+	}
+
+	redirectsTotal.With(target.String()).Inc()
+
+	log := client.Logger().WithField("target", target.String())
+	err := redirect(client, target)
+	if err != nil {
+		log.Warnf("failed to redirect: %v", err)
+		return true
+	}
+
+	log.Infof("redirected")
+	return true
+}
+
+// redirect tells the client to reconnect to the target.
+func redirect(client *ClientConn, target RedirectTarget) error {
+	if deadliner, ok := client.ReadWriteCloser.(writeDeadliner); ok {
+		err := deadliner.SetWriteDeadline(time.Now().Add(disconnectWriteTimeout))
+		if err != nil {
+			return err
+		}
+	}
+
+	return client.Send(&ReconnectionNotification{Host: target.Host, Port: target.Port})
+}
@@ -0,0 +1,83 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRedirectPolicies(t *testing.T) {
+	targets := []RedirectTarget{{"a", 1}, {"b", 2}, {"c", 3}}
+	client := &ClientConn{User: "0110000100000001"}
+
+	roundRobin := RoundRobinRedirect(targets)
+	for i := 0; i < 2*len(targets); i++ {
+		target, ok := roundRobin.Redirect(client)
+		if !ok || target != targets[i%len(targets)] {
+			t.Errorf("round robin %d: unexpected target %v", i, target)
+		}
+	}
+
+	hash := HashRedirect(targets)
+	first, ok := hash.Redirect(client)
+	if !ok {
+		t.Error("hash did not redirect")
+	}
+	for i := 0; i < 3; i++ {
+		if target, _ := hash.Redirect(client); target != first {
+			t.Errorf("hash is not sticky: %v != %v", target, first)
+		}
+	}
+
+	if _, ok := HashRedirect(nil).Redirect(client); ok {
+		t.Error("redirected without targets")
+	}
+
+	if target, ok := StaticRedirect(targets[1]).Redirect(client); !ok || target != targets[1] {
+		t.Errorf("unexpected static target %v", target)
+	}
+}
+
+func TestParseRedirectTarget(t *testing.T) {
+	target, err := ParseRedirectTarget("game.example.com:12501")
+	if err != nil || target != (RedirectTarget{"game.example.com", 12501}) || target.String() != "game.example.com:12501" {
+		t.Errorf("unexpected target %v %v", target, err)
+	}
+
+	for _, invalid := range []string{"game.example.com", ":12501", "game.example.com:0", "game.example.com:70000"} {
+		_, err = ParseRedirectTarget(invalid)
+		if err == nil {
+			t.Errorf("accepted %s", invalid)
+		}
+	}
+}
+
+func TestServerRedirectsAfterAuthentication(t *testing.T) {
+	serverA := NewInProcessServer(ServerConfig{RedirectPolicy: StaticRedirect(RedirectTarget{"b", 2})}, newMemoryDatabase())
+	serverB := NewInProcessServer(ServerConfig{}, newMemoryDatabase())
+
+	c := NewClient(ClientConfig{Host: "a", Port: 1, User: "0110000100000001", Resilient: true})
+	c.cfg.Dial = serverDialer(map[string]*Server{"a:1": serverA, "b:2": serverB}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := c.GetOnlineUrDragon(ctx)
+	if err != nil {
+		t.Errorf("redirected call failed: %v", err)
+		return
+	}
+
+	if c.cfg.Host != "b" || c.cfg.Port != 2 {
+		t.Errorf("client was not redirected: %s:%d", c.cfg.Host, c.cfg.Port)
+	}
+
+	serverA.SetRedirectPolicy(nil)
+	c.cfg.Host, c.cfg.Port = "a", 1
+	c.Disconnect(ctx)
+
+	_, err = c.GetOnlineUrDragon(ctx)
+	if err != nil || c.cfg.Host != "a" {
+		t.Errorf("client was redirected after the policy was removed: %s %v", c.cfg.Host, err)
+	}
+}
@@ -0,0 +1,133 @@
+package network
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Exported packet type ids for packets registered outside of this package.
+const (
+	RequestTypeID      = requestID
+	ResponseTypeID     = responseID
+	NotificationTypeID = notificationID
+)
+
+type packetRegistration struct {
+	PacketType PacketType
+	Name       string
+	New        func() Packet
+	goType     reflect.Type
+}
+
+type packetRegistry struct {
+	mutex   sync.RWMutex
+	byType  map[PacketType]*packetRegistration
+	byValue map[reflect.Type]*packetRegistration
+	names   map[PacketNameID]string
+}
+
+var packets = &packetRegistry{
+	byType:  make(map[PacketType]*packetRegistration),
+	byValue: make(map[reflect.Type]*packetRegistration),
+	names:   make(map[PacketNameID]string),
+}
+
+// RegisterPacket makes a packet known to the connection decoding, GetPacketType and PacketType.String.
+// Every packet type and Go type can only be registered once and all packets sharing a name id must share its name.
+func RegisterPacket(nameID PacketNameID, typeID PacketTypeID, name string, newPacket func() Packet) error {
+	return packets.register(nameID, typeID, name, newPacket)
+}
+
+func mustRegisterPacket(nameID PacketNameID, typeID PacketTypeID, name string, newPacket func() Packet) {
+	err := RegisterPacket(nameID, typeID, name, newPacket)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (r *packetRegistry) register(nameID PacketNameID, typeID PacketTypeID, name string, newPacket func() Packet) error {
+	packetType := PacketType{nameID, typeID, noErrorID}
+
+	if len(name) == 0 {
+		return fmt.Errorf("packet %x %x has no name", nameID, typeID)
+	}
+
+	p := newPacket()
+	if p == nil {
+		return fmt.Errorf("packet %s returned a nil packet", name)
+	}
+	goType := reflect.TypeOf(p)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.byType[packetType]; ok {
+		return fmt.Errorf("packet %x %x is already registered as %v", nameID, typeID, existing.goType)
+	}
+
+	if existing, ok := r.byValue[goType]; ok {
+		return fmt.Errorf("packet %v is already registered as %v", goType, &existing.PacketType)
+	}
+
+	if existingName, ok := r.names[nameID]; ok && existingName != name {
+		return fmt.Errorf("packet %x is already named %s", nameID, existingName)
+	}
+
+	registration := &packetRegistration{
+		PacketType: packetType,
+		Name:       name,
+		New:        newPacket,
+		goType:     goType,
+	}
+	r.byType[packetType] = registration
+	r.byValue[goType] = registration
+	r.names[nameID] = name
+
+	return nil
+}
+
+func (r *packetRegistry) lookupType(packetType PacketType) (*packetRegistration, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	registration, ok := r.byType[packetType]
+	return registration, ok
+}
+
+func (r *packetRegistry) lookupValue(p Packet) (*packetRegistration, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	registration, ok := r.byValue[reflect.TypeOf(p)]
+	return registration, ok
+}
+
+func (r *packetRegistry) name(nameID PacketNameID) (string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	name, ok := r.names[nameID]
+	return name, ok
+}
+
+func (r *packetRegistry) all() []packetRegistration {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	registrations := make([]packetRegistration, 0, len(r.byType))
+	for _, registration := range r.byType {
+		registrations = append(registrations, *registration)
+	}
+
+	sort.Slice(registrations, func(i, j int) bool {
+		a, b := registrations[i].PacketType, registrations[j].PacketType
+		if a.NameID != b.NameID {
+			return a.NameID < b.NameID
+		}
+		return a.TypeID < b.TypeID
+	})
+
+	return registrations
+}
@@ -0,0 +1,117 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegisteredPacketsRoundTrip(t *testing.T) {
+	registrations := packets.all()
+	if len(registrations) == 0 {
+		t.Error("no packets registered")
+		return
+	}
+
+	for _, registration := range registrations {
+		packetType := registration.PacketType
+		p := registration.New()
+
+		actualType := GetPacketType(p)
+		if actualType != packetType {
+			t.Errorf("%v: GetPacketType mismatch %v", &packetType, &actualType)
+			continue
+		}
+
+		payload, err := p.Payload()
+		if err != nil {
+			t.Errorf("%v: failed to write payload: %v", &packetType, err)
+			continue
+		}
+
+		header := PacketHeader{Length: uint16(len(payload)), SequenceID: 1, PacketType: packetType}
+		decoded, err := NewPacketFromHeader(header)
+		if err != nil {
+			t.Errorf("%v: %v", &packetType, err)
+			continue
+		}
+
+		if reflect.TypeOf(decoded) != reflect.TypeOf(p) {
+			t.Errorf("%v: decoded as %T instead of %T", &packetType, decoded, p)
+			continue
+		}
+
+		err = decoded.SetPayload(payload)
+		if err != nil {
+			t.Errorf("%v: failed to read payload: %v", &packetType, err)
+			continue
+		}
+
+		decodedPayload, err := decoded.Payload()
+		if err != nil {
+			t.Errorf("%v: failed to write decoded payload: %v", &packetType, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(payload, decodedPayload) {
+			t.Errorf("%v: payload mismatch %x %x", &packetType, payload, decodedPayload)
+		}
+
+		if name := packetName(packetType.NameID); name != registration.Name {
+			t.Errorf("%v: name mismatch %s %s", &packetType, name, registration.Name)
+		}
+	}
+}
+
+type testExtensionPacket struct {
+	EmptyPacket
+}
+
+type testOtherExtensionPacket struct {
+	EmptyPacket
+}
+
+func TestRegisterPacket(t *testing.T) {
+	r := &packetRegistry{
+		byType:  make(map[PacketType]*packetRegistration),
+		byValue: make(map[reflect.Type]*packetRegistration),
+		names:   make(map[PacketNameID]string),
+	}
+
+	err := r.register(0x1301, requestID, "extension", func() Packet { return &testExtensionPacket{} })
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = r.register(0x1301, requestID, "extension", func() Packet { return &testOtherExtensionPacket{} })
+	if err == nil {
+		t.Error("duplicate packet type was registered")
+	}
+
+	err = r.register(0x1301, responseID, "extension", func() Packet { return &testExtensionPacket{} })
+	if err == nil {
+		t.Error("duplicate go type was registered")
+	}
+
+	err = r.register(0x1301, responseID, "other", func() Packet { return &testOtherExtensionPacket{} })
+	if err == nil {
+		t.Error("conflicting name was registered")
+	}
+
+	err = r.register(0x1301, responseID, "extension", func() Packet { return &testOtherExtensionPacket{} })
+	if err != nil {
+		t.Error(err)
+	}
+
+	registration, ok := r.lookupValue(&testOtherExtensionPacket{})
+	if !ok || registration.PacketType != (PacketType{0x1301, responseID, noErrorID}) {
+		t.Error("registered packet not found")
+	}
+}
+
+func TestRegisterPacketRejectsBuiltinDuplicate(t *testing.T) {
+	err := RegisterPacket(onlineCheckID, requestID, "onlineCheck", func() Packet { return &testExtensionPacket{} })
+	if err == nil {
+		t.Error("builtin packet type was registered again")
+	}
+}
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type FieldDiff struct {
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: expected %s, got %s", d.Field, d.Expected, d.Actual)
+}
+
+// DiffPackets compares the packet types and all payload fields of two packets.
+// The packet headers are ignored because the sequence ids differ between sessions.
+func DiffPackets(expected Packet, actual Packet) []FieldDiff {
+	var diffs []FieldDiff
+
+	expectedType, actualType := GetPacketType(expected), GetPacketType(actual)
+	if expectedType != actualType {
+		return append(diffs, FieldDiff{"PacketType", expectedType.String(), actualType.String()})
+	}
+
+	return diffValues(diffs, "", reflect.ValueOf(expected), reflect.ValueOf(actual))
+}
+
+var (
+	packetHeaderType = reflect.TypeOf(PacketHeader{})
+	byteSliceType    = reflect.TypeOf([]byte(nil))
+)
+
+func diffValues(diffs []FieldDiff, field string, expected reflect.Value, actual reflect.Value) []FieldDiff {
+	switch expected.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if expected.IsNil() || actual.IsNil() {
+			if expected.IsNil() != actual.IsNil() {
+				diffs = append(diffs, FieldDiff{field, formatValue(expected), formatValue(actual)})
+			}
+			return diffs
+		}
+
+		return diffValues(diffs, field, expected.Elem(), actual.Elem())
+	case reflect.Struct:
+		for i := 0; i < expected.NumField(); i++ {
+			f := expected.Type().Field(i)
+			if f.Type == packetHeaderType || f.PkgPath != "" || f.Name == "_" {
+				continue
+			}
+
+			name := f.Name
+			if f.Anonymous {
+				name = ""
+			}
+
+			diffs = diffValues(diffs, joinField(field, name), expected.Field(i), actual.Field(i))
+		}
+
+		return diffs
+	case reflect.Slice, reflect.Array:
+		if expected.Type() == byteSliceType {
+			if !reflect.DeepEqual(expected.Interface(), actual.Interface()) {
+				diffs = append(diffs, FieldDiff{field, formatValue(expected), formatValue(actual)})
+			}
+			return diffs
+		}
+
+		if expected.Len() != actual.Len() {
+			diffs = append(diffs, FieldDiff{field + ".len", fmt.Sprint(expected.Len()), fmt.Sprint(actual.Len())})
+		}
+
+		for i := 0; i < expected.Len() && i < actual.Len(); i++ {
+			diffs = diffValues(diffs, fmt.Sprintf("%s[%d]", field, i), expected.Index(i), actual.Index(i))
+		}
+
+		return diffs
+	default:
+		if expected.Interface() != actual.Interface() {
+			diffs = append(diffs, FieldDiff{field, formatValue(expected), formatValue(actual)})
+		}
+
+		return diffs
+	}
+}
+
+func joinField(parent string, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+
+	if len(name) == 0 {
+		return parent
+	}
+
+	return parent + "." + name
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Type() == byteSliceType {
+		return fmt.Sprintf("%x", v.Bytes())
+	}
+
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+type ReplayResult struct {
+	Record   *CaptureRecord
+	Expected Packet
+	Actual   Packet
+	Diffs    []FieldDiff
+}
+
+// Replay sends the recorded client packets over conn in their original order
+// and compares every packet the server answers with against the recorded one.
+// Online checks are skipped because they depend on the timing of the original session.
+func Replay(conn *ClientConn, records []*CaptureRecord, report func(result *ReplayResult)) error {
+	for _, record := range records {
+		expected, err := record.Packet()
+		if err != nil {
+			return fmt.Errorf("invalid capture record %v: %v", &record.Header, err)
+		}
+
+		if record.Header.PacketType.NameID == onlineCheckID {
+			continue
+		}
+
+		switch record.Direction {
+		case ClientToServer:
+			err = conn.Send(expected)
+			if err != nil {
+				return err
+			}
+		case ServerToClient:
+			actual, err := conn.Recv()
+			if protocolErr, ok := err.(*ProtocolError); ok {
+				actual, err = protocolErr.Response, nil
+			}
+			if err != nil {
+				return fmt.Errorf("expected %v: %v", &record.Header, err)
+			}
+
+			report(&ReplayResult{
+				Record:   record,
+				Expected: expected,
+				Actual:   actual,
+				Diffs:    DiffPackets(expected, actual),
+			})
+		default:
+			return fmt.Errorf("invalid capture direction %v", record.Direction)
+		}
+	}
+
+	return nil
+}
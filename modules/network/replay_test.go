@@ -0,0 +1,102 @@
+package network
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
+)
+
+func TestDiffPackets(t *testing.T) {
+	expected := &TusCommonAreaAcquisitionResponse{PropertyPacket{Properties: []Property{{Index: 1, Value1: 2, Value2: 3}}}}
+	actual := &TusCommonAreaAcquisitionResponse{PropertyPacket{Properties: []Property{{Index: 1, Value1: 5, Value2: 3}, {Index: 2}}}}
+	actual.SequenceID = 42
+
+	diffs := DiffPackets(expected, actual)
+	expectedDiffs := []FieldDiff{
+		{"Properties.len", "1", "2"},
+		{"Properties[0].Value1", "2", "5"},
+	}
+	if !reflect.DeepEqual(diffs, expectedDiffs) {
+		t.Errorf("unexpected diffs %v", diffs)
+	}
+
+	diffs = DiffPackets(expected, NewErrorResponse(tusCommonAreaAcquisitionID, storageErrorID))
+	if len(diffs) != 1 || diffs[0].Field != "PacketType" {
+		t.Errorf("unexpected diffs %v", diffs)
+	}
+
+	diffs = DiffPackets(&FastDataResponse{User: "a"}, &FastDataResponse{User: "a"})
+	if len(diffs) != 0 {
+		t.Errorf("equal packets differ %v", diffs)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	database := newMemoryDatabase()
+	database.dragon.Generation = 3
+
+	server := NewInProcessServer(ServerConfig{}, database)
+	serverSide, clientSide := net.Pipe()
+	serverErrs := make(chan error, 1)
+	go func() {
+		serverErrs <- server.ServeConn(serverSide)
+	}()
+
+	indices := []byte{0}
+	recordedProps, _ := (&game.OnlineUrDragon{Generation: 2}).PropertiesFiltered(indices)
+
+	var records []*CaptureRecord
+	record := func(direction CaptureDirection, p Packet) {
+		payload, _ := p.Payload()
+		records = append(records, &CaptureRecord{
+			Direction: direction,
+			Header:    PacketHeader{Length: uint16(len(payload)), PacketType: GetPacketType(p)},
+			Payload:   payload,
+		})
+	}
+	record(ServerToClient, &FastDataRequest{})
+	record(ClientToServer, &FastDataResponse{Unknown1: 0x03, Unknown2: 0x01, User: "0110000100000001"})
+	record(ServerToClient, &ConnectionSummaryNotification{Success: true, Unknown: 10})
+	record(ClientToServer, &AuthenticationInformationRequestHeader{Unknown: 0x02, DataLength: 0})
+	record(ServerToClient, &AuthenticationInformationResponseHeader{ChunkLength: defaultAuthChunkLength})
+	record(ClientToServer, &AuthenticationInformationRequestFooter{})
+	record(ServerToClient, &AuthenticationInformationResponseFooter{BooleanPacket{Value: true}})
+	record(ClientToServer, &TusCommonAreaAcquisitionRequest{PropertyIndices: indices})
+	record(ServerToClient, &TusCommonAreaAcquisitionResponse{PropertyPacket{Properties: dragonToNetworkProperties(recordedProps)}})
+	record(ClientToServer, &DisconnectionRequest{BooleanPacket{Value: true}})
+	record(ServerToClient, &DisconnectionResponse{BooleanPacket{Value: true}})
+
+	var results []*ReplayResult
+	err := Replay(NewClientConn(clientSide, 0, false), records, func(result *ReplayResult) {
+		results = append(results, result)
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = <-serverErrs
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(results) != 6 {
+		t.Errorf("unexpected result count %d", len(results))
+		return
+	}
+
+	for i, result := range results {
+		if i == 4 {
+			if len(result.Diffs) != 1 || result.Diffs[0].Field != "Properties[0].Value2" {
+				t.Errorf("unexpected acquisition diffs %v", result.Diffs)
+			}
+			continue
+		}
+
+		if len(result.Diffs) != 0 {
+			t.Errorf("%v differs: %v", &result.Record.Header, result.Diffs)
+		}
+	}
+}
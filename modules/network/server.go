@@ -1,30 +1,67 @@
 package network
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/atvaark/dragons-dogma-server/modules/game"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
 )
 
 type Server struct {
-	config   ServerConfig
-	database game.Database
-	listener *serverListener
+	config           ServerConfig
+	database         game.Database
+	listenerMutex    sync.Mutex
+	listener         *serverListener
+	capture          *CaptureWriter
+	unknownPackets   *UnknownPacketArchive
+	pipeConnID       int64
+	clientsMutex     sync.Mutex
+	clients          map[int64]*ClientConn
+	requestsMutex    sync.RWMutex
+	requests         sync.WaitGroup
+	shuttingDown     int32
+	maintenanceMutex sync.RWMutex
+	maintenance      Maintenance
+	redirectMutex    sync.RWMutex
+	redirectPolicy   RedirectPolicy
+	handlersMutex    sync.RWMutex
+	handlers         map[PacketNameID]Handler
+	middlewares      []Middleware
 }
 
 type ServerConfig struct {
-	Port      int
-	CertFile  string
-	KeyFile   string
-	tlsConfig *tls.Config
+	Port                int
+	CertFile            string
+	KeyFile             string
+	TokenVerifier       TokenVerifier
+	AuthChunkLength     uint16
+	HandshakeTimeout    time.Duration
+	OnlineCheckInterval time.Duration
+	OnlineCheckTimeout  time.Duration
+	CaptureFile         string
+	UnknownPacketDir    string
+	ShutdownMessage     string
+	Maintenance         Maintenance
+	RedirectPolicy      RedirectPolicy
+	Logger              *logging.Logger
+	tlsConfig           *tls.Config
 }
 
+const (
+	defaultHandshakeTimeout     = 30 * time.Second
+	defaultAuthChunkLength      = 256
+	maxAuthenticationDataLength = 8192
+)
+
 func NewServer(cfg ServerConfig, database game.Database) (*Server, error) {
 	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
 	if err != nil {
@@ -37,10 +74,91 @@ func NewServer(cfg ServerConfig, database game.Database) (*Server, error) {
 		MaxVersion: tls.VersionTLS10,
 	}
 
-	return &Server{
-		config:   cfg,
-		database: database,
-	}, nil
+	s := newServer(cfg, database)
+
+	if len(cfg.UnknownPacketDir) > 0 {
+		s.unknownPackets, err = NewUnknownPacketArchive(cfg.UnknownPacketDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.CaptureFile) > 0 {
+		s.capture, err = OpenCaptureFile(cfg.CaptureFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func newServer(cfg ServerConfig, database game.Database) *Server {
+	if cfg.TokenVerifier == nil {
+		cfg.TokenVerifier = AllowAllTokenVerifier{}
+	}
+
+	if cfg.HandshakeTimeout <= 0 {
+		cfg.HandshakeTimeout = defaultHandshakeTimeout
+	}
+
+	if cfg.OnlineCheckInterval <= 0 {
+		cfg.OnlineCheckInterval = defaultOnlineCheckInterval
+	}
+
+	if cfg.OnlineCheckTimeout <= 0 {
+		cfg.OnlineCheckTimeout = defaultOnlineCheckTimeout
+	}
+
+	if len(cfg.ShutdownMessage) == 0 {
+		cfg.ShutdownMessage = defaultShutdownMessage
+	}
+
+	s := &Server{
+		config:         cfg,
+		database:       database,
+		handlers:       make(map[PacketNameID]Handler),
+		clients:        make(map[int64]*ClientConn),
+		maintenance:    cfg.Maintenance,
+		redirectPolicy: cfg.RedirectPolicy,
+	}
+	s.registerDefaultHandlers()
+	s.Use(MetricsMiddleware(serverRequestObserver{}))
+
+	return s
+}
+
+// NewInProcessServer creates a server that does not listen on its own.
+// Connections are handed to it with ServeConn, for example one side of a net.Pipe.
+func NewInProcessServer(cfg ServerConfig, database game.Database) *Server {
+	return newServer(cfg, database)
+}
+
+// ServeConn authenticates and serves a single connection that does not need TLS.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) error {
+	defer conn.Close()
+
+	connID := atomic.AddInt64(&s.pipeConnID, 1)
+	client, err := s.authenticate(conn, connID)
+	if err == ErrMaintenance {
+		handshakeFailures.With(handshakeStageMaintenance).Inc()
+		return err
+	}
+	if err != nil {
+		handshakeFailures.With(handshakeStageAuth).Inc()
+		return err
+	}
+
+	if s.redirectClient(client) {
+		return nil
+	}
+
+	client.EnableKeepAlive(s.config.OnlineCheckInterval, s.config.OnlineCheckTimeout)
+
+	activeConnections.Inc()
+	defer activeConnections.Dec()
+
+	return s.handleClient(client)
 }
 
 func (s *Server) ListenAndServe() error {
@@ -54,18 +172,26 @@ func (s *Server) ListenAndServe() error {
 		listener:    tlsListener,
 		connections: make(map[int64]net.Conn, 0),
 		close:       make(chan bool, 1),
+		log:         s.config.Logger,
+	}
+
+	// Shutdown reads the listener under the same mutex, so it either closes this listener or we see it has started.
+	s.listenerMutex.Lock()
+	if s.isShuttingDown() {
+		s.listenerMutex.Unlock()
+		return tlsListener.Close()
 	}
 	s.listener = &listener
+	s.listenerMutex.Unlock()
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			select {
 			case <-listener.close:
-				s.listener.CloseConns()
 				return nil
 			default:
-				printf("%v", err)
+				s.config.Logger.Warnf("accept failed: %v", err)
 				continue
 			}
 		}
@@ -74,13 +200,14 @@ func (s *Server) ListenAndServe() error {
 	}
 }
 
+// Close notifies and closes all clients without waiting for their requests. Use Shutdown to let them finish.
 func (s *Server) Close() error {
-	l := s.listener
-	if l != nil {
-		err := l.Close()
-		if err != nil {
-			return err
-		}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.Shutdown(ctx)
+	if err != nil && err != context.Canceled {
+		return err
 	}
 
 	return nil
@@ -91,38 +218,72 @@ func (s *Server) handleConnection(conn net.Conn) {
 	defer s.listener.DelConn(connID)
 	defer conn.Close()
 
-	printf("[%d] connecting\n", connID)
+	log := s.config.Logger.WithFields(logging.Fields{"conn": connID, "remoteAddress": conn.RemoteAddr().String()})
+	log.Debugf("connecting")
 
 	tlsConn, ok := conn.(*tls.Conn)
 	if !ok {
-		printf("[%d] no TLS connection\n", connID)
+		handshakeFailures.With(handshakeStageTLS).Inc()
+		log.Warnf("no TLS connection")
+		return
+	}
+
+	err := tlsConn.SetDeadline(time.Now().Add(s.config.HandshakeTimeout))
+	if err != nil {
+		handshakeFailures.With(handshakeStageDeadline).Inc()
+		log.Warnf("failed to set the handshake deadline: %v", err)
 		return
 	}
 
-	err := tlsConn.Handshake()
+	err = tlsConn.Handshake()
+	if err != nil {
+		handshakeFailures.With(handshakeStageTLS).Inc()
+		log.Warnf("TLS handshake failed: %v", err)
+		return
+	}
+
+	client, err := s.authenticate(tlsConn, connID)
+	if err == ErrMaintenance {
+		handshakeFailures.With(handshakeStageMaintenance).Inc()
+		log.Infof("%v", err)
+		return
+	}
 	if err != nil {
-		printf("[%d] TLS handshake failed:%v\n", connID, err)
+		handshakeFailures.With(handshakeStageAuth).Inc()
+		log.Warnf("auth failed: %v", err)
 		return
 	}
 
-	client, err := authenticate(tlsConn, connID)
+	if s.redirectClient(client) {
+		return
+	}
+
+	err = tlsConn.SetDeadline(time.Time{})
 	if err != nil {
-		printf("[%d] auth failed: %v\n", connID, err)
+		handshakeFailures.With(handshakeStageDeadline).Inc()
+		client.Logger().Warnf("failed to clear the handshake deadline: %v", err)
 		return
 	}
 
-	printf("%v connected\n", client)
+	client.EnableKeepAlive(s.config.OnlineCheckInterval, s.config.OnlineCheckTimeout)
+
+	activeConnections.Inc()
+	defer activeConnections.Dec()
+
+	client.Logger().Infof("connected")
 
 	err = s.handleClient(client)
 	if err != nil {
-		printf("%v failed to handle request: %v\n", client, err)
+		client.Logger().Warnf("failed to handle request: %v", err)
 	}
 
-	printf("%v disconnected\n", client)
+	client.Logger().Infof("disconnected")
 }
 
-func authenticate(conn *tls.Conn, connID int64) (*ClientConn, error) {
+func (s *Server) authenticate(conn io.ReadWriteCloser, connID int64) (*ClientConn, error) {
 	client := NewClientConn(conn, connID, true)
+	client.SetCapture(s.capture)
+	client.SetLogger(s.config.Logger)
 	var err error
 	var response Packet
 
@@ -141,6 +302,15 @@ func authenticate(conn *tls.Conn, connID int64) (*ClientConn, error) {
 
 	client.User = fastDataResponse.User
 
+	if maintenance := s.Maintenance(); maintenance.Enabled {
+		err = refuseLogin(client, maintenance)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrMaintenance
+	}
+
 	err = client.Send(&ConnectionSummaryNotification{Success: true, Unknown: 10})
 	if err != nil {
 		return nil, err
@@ -154,248 +324,311 @@ func authenticate(conn *tls.Conn, connID int64) (*ClientConn, error) {
 	if !ok {
 		return nil, NewPacketTypeError(authenticationInformationRequestHeader, response)
 	}
-	_ = authenticationInformationRequestHeader
-	err = client.Send(&AuthenticationInformationResponseHeader{ChunkLength: 256})
-	if err != nil {
-		return nil, err
+
+	dataLength := int(authenticationInformationRequestHeader.DataLength)
+	if dataLength > maxAuthenticationDataLength {
+		return nil, fmt.Errorf("authentication data exceeds max size: %d > %d", dataLength, maxAuthenticationDataLength)
 	}
 
-	response, err = client.Recv()
+	chunkLength := negotiateChunkLength(s.config.AuthChunkLength, dataLength)
+	err = client.Send(&AuthenticationInformationResponseHeader{ChunkLength: chunkLength})
 	if err != nil {
 		return nil, err
 	}
-	authenticationInformationRequestData, ok := response.(*AuthenticationInformationRequestData)
-	if !ok {
-		return nil, NewPacketTypeError(authenticationInformationRequestData, response)
-	}
-	err = client.Send(&AuthenticationInformationResponseData{DataChunkReferencePacket{ChunkLength: uint16(len(authenticationInformationRequestData.ChunkData))}})
+
+	token, err := recvAuthenticationData(client, dataLength, chunkLength)
 	if err != nil {
 		return nil, err
 	}
 
-	response, err = client.Recv()
+	identity, verifyErr := s.config.TokenVerifier.VerifyToken(client.User, token)
+	err = client.Send(&AuthenticationInformationResponseFooter{BooleanPacket{Value: verifyErr == nil}})
 	if err != nil {
 		return nil, err
 	}
-	authenticationInformationRequestFooter, ok := response.(*AuthenticationInformationRequestFooter)
-	_ = authenticationInformationRequestFooter
-	if !ok {
-		return nil, NewPacketTypeError(authenticationInformationRequestFooter, response)
-	}
-	err = client.Send(&AuthenticationInformationResponseFooter{BooleanPacket{Value: true}})
-	if err != nil {
-		return nil, err
+
+	if verifyErr != nil {
+		return nil, fmt.Errorf("token verification failed: %v", verifyErr)
 	}
 
+	client.User = identity.User
+
 	return client, nil
 }
 
-func (s *Server) handleClient(client *ClientConn) error {
-	// TODO: Return an error packet to the client in case of errors
+func negotiateChunkLength(preferredChunkLength uint16, dataLength int) uint16 {
+	chunkLength := int(preferredChunkLength)
+	if chunkLength == 0 {
+		chunkLength = defaultAuthChunkLength
+	}
 
-	for {
-		// TODO: Send an onlineCheckRequest if no request was sent after 60s
-		//       Disconnect the client if no onlineCheckResponse gets received after 30s
+	if chunkLength > maxChunkLength {
+		chunkLength = maxChunkLength
+	}
+
+	if dataLength > 0 && dataLength < chunkLength {
+		chunkLength = dataLength
+	}
+
+	return uint16(chunkLength)
+}
+
+func recvAuthenticationData(client *ClientConn, dataLength int, chunkLength uint16) ([]byte, error) {
+	assembler := newChunkAssembler(dataLength, int(chunkLength))
 
+	for {
 		request, err := client.Recv()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		switch request := request.(type) {
-		case *TusCommonAreaAcquisitionRequest:
-			dragon, err := s.database.GetOnlineUrDragon()
-			if err != nil {
-				return err
+		case *AuthenticationInformationRequestFooter:
+			if !assembler.Complete() {
+				return nil, errors.New("authentication data incomplete")
 			}
 
-			dragonProps, err := dragon.PropertiesFiltered(request.PropertyIndices)
+			return assembler.Bytes(), nil
+		case *AuthenticationInformationRequestData:
+			err = assembler.Write(int(request.ChunkOffset), request.ChunkData)
 			if err != nil {
-				return err
+				return nil, fmt.Errorf("invalid authentication data: %v", err)
 			}
 
-			err = client.Send(&TusCommonAreaAcquisitionResponse{PropertyPacket{Properties: dragonToNetworkProperties(dragonProps)}})
+			err = client.Send(&AuthenticationInformationResponseData{DataChunkReferencePacket{ChunkOffset: request.ChunkOffset, ChunkLength: uint16(len(request.ChunkData))}})
 			if err != nil {
-				return err
-			}
-		case *TusCommonAreaAddRequest:
-			dragon, err := s.database.GetOnlineUrDragon()
-			if err != nil {
-				return err
+				return nil, err
 			}
+		default:
+			return nil, fmt.Errorf("authentication failed: unexpected request %T", request)
+		}
+	}
+}
 
-			dragonProps, err := dragon.AddProperties(networkToDragonProperties(request.Properties))
-			if err != nil {
-				return err
-			}
+func (s *Server) handleClient(client *ClientConn) error {
+	if !s.addClient(client) {
+		return disconnectAndClose(client, s.config.ShutdownMessage)
+	}
+	defer s.removeClient(client)
 
-			err = s.database.PutOnlineUrDragon(dragon)
-			if err != nil {
-				return err
-			}
+	for {
+		request, err := client.Recv()
+		if err == ErrOnlineCheckTimeout {
+			client.Logger().Infof("did not answer the online check")
+			return disconnect(client, "")
+		}
+		if err != nil && client.isClosed() {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 
-			err = client.Send(&TusCommonAreaAddResponse{PropertyPacket{Properties: dragonToNetworkProperties(dragonProps)}})
-			if err != nil {
-				return err
-			}
-		case *TusCommonAreaSettingsRequest:
-			dragon, err := s.database.GetOnlineUrDragon()
-			if err != nil {
-				return err
-			}
+		if !s.beginRequest() {
+			return disconnectAndClose(client, s.config.ShutdownMessage)
+		}
 
-			err = dragon.SetProperties(networkToDragonProperties(request.Properties))
-			if err != nil {
-				return err
-			}
+		err = s.serveRequest(client, request)
+		s.endRequest()
+		if err == ErrClientDisconnected {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
 
-			err = s.database.PutOnlineUrDragon(dragon)
-			if err != nil {
-				return err
-			}
+// serveRequest dispatches the request and answers recoverable request errors with an error response.
+func (s *Server) serveRequest(client *ClientConn, request Packet) error {
+	err := s.dispatch(client, request)
+	if err == nil || err == ErrClientDisconnected {
+		return err
+	}
 
-			err = client.Send(&TusCommonAreaSettingsResponse{PropertyPacket{Properties: request.Properties}})
-			if err != nil {
-				return err
-			}
-		case *DisconnectionRequest:
-			err = client.Send(&DisconnectionResponse{BooleanPacket{Value: true}})
-			if err != nil {
-				return err
-			}
+	reqErr, ok := err.(*RequestError)
+	if !ok {
+		return err
+	}
 
-			return nil
-		case *TusUserAreaReadRequestHeader:
-			userID, err := strconv.ParseUint(request.User, 16, 64)
-			if err != nil {
-				return err
-			}
+	client.Logger().WithFields(packetLogFields(reqErr.PacketType)).Warnf("%v failed: %v", &reqErr.PacketType, reqErr)
 
-			rewards, err := s.database.GetPawnRewards(userID)
-			if err != nil {
-				return err
-			}
+	err = client.Send(NewErrorResponse(reqErr.PacketType.NameID, reqErr.ErrorID))
+	if err != nil {
+		return err
+	}
 
-			area := pawnRewardsToUserArea(rewards)
-			areaData, err := WriteUserArea(area)
-			if err != nil {
-				return err
-			}
+	if !reqErr.Recoverable {
+		return reqErr
+	}
 
-			err = client.Send(&TusUserAreaReadResponseHeader{DataLength: uint32(len(areaData))})
-			if err != nil {
-				return err
-			}
+	return nil
+}
 
-		ReadLoop:
-			for {
-				response, err := client.Recv()
-				if err != nil {
-					return err
-				}
-
-				switch response := response.(type) {
-				case *TusUserAreaReadRequestFooter:
-					err = client.Send(&TusUserAreaReadResponseFooter{})
-					if err != nil {
-						return err
-					}
-
-					break ReadLoop
-				case *TusUserAreaReadRequestData:
-					chunkOffset := int(response.ChunkOffset)
-					chunkLength := int(response.ChunkLength)
-
-					if chunkLength > maxChunkLength || chunkOffset+chunkLength > len(areaData) {
-						return errors.New("read user area failed: invalid size")
-					}
-
-					chunkData := areaData[chunkOffset : chunkOffset+chunkLength]
-					err = client.Send(&TusUserAreaReadResponseData{DataChunkPacket{ChunkOffset: uint32(chunkOffset), ChunkData: chunkData}})
-					if err != nil {
-						return err
-					}
-				default:
-					return fmt.Errorf("read user area failed: unexpected response %T", response)
-				}
-			}
-		case *TusUserAreaWriteRequestHeader:
-			userID, err := strconv.ParseUint(request.User, 16, 64)
-			if err != nil {
-				return err
-			}
+const userAreaChunkLength = uint16(1024)
+
+func (s *Server) registerDefaultHandlers() {
+	s.HandleFunc(disconnectionID, handleDisconnection)
+	s.HandleFunc(tusCommonAreaAcquisitionID, s.handleCommonAreaAcquisition)
+	s.HandleFunc(tusCommonAreaAddID, s.handleCommonAreaAdd)
+	s.HandleFunc(tusCommonAreaSettingsID, s.handleCommonAreaSettings)
+	s.Handle(tusUserAreaReadHeaderID, &UserAreaReadTransaction{
+		Load: s.loadUserArea,
+	})
+	s.Handle(tusUserAreaWriteHeaderID, &UserAreaWriteTransaction{
+		ChunkLength: userAreaChunkLength,
+		Validate:    validateUserAreaWrite,
+		Store:       s.storeUserArea,
+	})
+}
 
-			if request.DataLength > maxDataLength {
-				return errors.New("write user area failed: invalid size")
-			}
+func handleDisconnection(client *ClientConn, request Packet) error {
+	if _, ok := request.(*DisconnectionRequest); !ok {
+		return NewRequestError(request, invalidRequestErrorID, NewPacketTypeError(&DisconnectionRequest{}, request))
+	}
 
-			areaData := make([]byte, int(request.DataLength))
+	err := client.Send(&DisconnectionResponse{BooleanPacket{Value: true}})
+	if err != nil {
+		return err
+	}
 
-			const chunkLength = uint16(1024)
-			err = client.Send(&TusUserAreaWriteResponseHeader{ChunkLength: chunkLength})
-			if err != nil {
-				return err
-			}
+	return ErrClientDisconnected
+}
 
-		WriteLoop:
-			for {
-				response, err := client.Recv()
-				if err != nil {
-					return err
-				}
-
-				switch response := response.(type) {
-				case *TusUserAreaWriteRequestFooter:
-					area, err := ReadUserArea(areaData)
-					if err != nil {
-						return err
-					}
-
-					rewards := userAreaToPawnRewards(userID, area)
-					err = s.database.PutPawnRewards(rewards)
-					if err != nil {
-						return err
-					}
-
-					err = client.Send(&TusUserAreaWriteResponseFooter{})
-					if err != nil {
-						return err
-					}
-
-					break WriteLoop
-				case *TusUserAreaWriteRequestData:
-					chunkOffset := int(response.ChunkOffset)
-					chunkData := response.ChunkData
-					chunkLength := len(chunkData)
-
-					if chunkLength > maxChunkLength || chunkOffset+chunkLength > len(areaData) {
-						return errors.New("write user area failed: invalid size")
-					}
-
-					copy(areaData[chunkOffset:chunkOffset+chunkLength], chunkData)
-
-					err = client.Send(&TusUserAreaWriteResponseData{DataChunkReferencePacket{ChunkOffset: uint32(chunkOffset), ChunkLength: uint16(chunkLength)}})
-					if err != nil {
-						return err
-					}
-				default:
-					return fmt.Errorf("write user area failed: unexpected response %T", response)
-				}
-			}
-		default:
-			printf("unhandled request: %v", request)
+func (s *Server) handleCommonAreaAcquisition(client *ClientConn, packet Packet) error {
+	request, ok := packet.(*TusCommonAreaAcquisitionRequest)
+	if !ok {
+		return NewRequestError(packet, invalidRequestErrorID, NewPacketTypeError(request, packet))
+	}
 
-			err = disconnect(client)
-			if err != nil {
-				printf("%v disconnect failed: %v\n", client, err)
-				return err
-			}
+	dragon, err := s.database.GetOnlineUrDragon()
+	if err != nil {
+		return NewRequestError(request, storageErrorID, err)
+	}
+
+	dragonProps, err := dragon.PropertiesFiltered(request.PropertyIndices)
+	if err != nil {
+		return NewRequestError(request, invalidPropertyIndexErrorID, err)
+	}
+
+	return client.Send(&TusCommonAreaAcquisitionResponse{PropertyPacket{Properties: dragonToNetworkProperties(dragonProps)}})
+}
+
+func (s *Server) handleCommonAreaAdd(client *ClientConn, packet Packet) error {
+	request, ok := packet.(*TusCommonAreaAddRequest)
+	if !ok {
+		return NewRequestError(packet, invalidRequestErrorID, NewPacketTypeError(request, packet))
+	}
+
+	var dragonProps []game.DragonProperty
+	var propErr error
+	err := s.database.UpdateOnlineUrDragon(func(dragon *game.OnlineUrDragon) error {
+		dragonProps, propErr = dragon.AddProperties(networkToDragonProperties(request.Properties))
+		return propErr
+	})
+	if propErr != nil {
+		return NewRequestError(request, invalidPropertyIndexErrorID, propErr)
+	}
+	if err != nil {
+		return NewRequestError(request, storageErrorID, err)
+	}
+
+	return client.Send(&TusCommonAreaAddResponse{PropertyPacket{Properties: dragonToNetworkProperties(dragonProps)}})
+}
+
+func (s *Server) handleCommonAreaSettings(client *ClientConn, packet Packet) error {
+	request, ok := packet.(*TusCommonAreaSettingsRequest)
+	if !ok {
+		return NewRequestError(packet, invalidRequestErrorID, NewPacketTypeError(request, packet))
+	}
+
+	var dragonProps []game.DragonProperty
+	var propErr error
+	err := s.database.UpdateOnlineUrDragon(func(dragon *game.OnlineUrDragon) error {
+		requestProps := networkToDragonProperties(request.Properties)
+		props, validations := dragon.ValidateProperties(requestProps)
+		log := client.Logger()
+		for _, v := range validations {
+			log.Infof("%s property %d (%d, %d): %s", v.Verdict, v.Property.Index, v.Property.Value1, v.Property.Value2, v.Reason)
 		}
+
+		propErr = dragon.SetProperties(props)
+		if propErr != nil {
+			return propErr
+		}
+
+		dragonProps, propErr = dragon.PropertiesFiltered(validPropertyIndices(requestProps))
+		return propErr
+	})
+	if propErr != nil {
+		return NewRequestError(request, invalidPropertyIndexErrorID, propErr)
+	}
+	if err != nil {
+		return NewRequestError(request, storageErrorID, err)
+	}
+
+	return client.Send(&TusCommonAreaSettingsResponse{PropertyPacket{Properties: dragonToNetworkProperties(dragonProps)}})
+}
+
+func (s *Server) loadUserArea(client *ClientConn, request *TusUserAreaReadRequestHeader) ([]byte, error) {
+	userID, err := strconv.ParseUint(request.User, 16, 64)
+	if err != nil {
+		return nil, NewRequestError(request, invalidUserErrorID, err)
+	}
+
+	rewards, err := s.database.GetPawnRewards(userID)
+	if err != nil {
+		return nil, NewRequestError(request, storageErrorID, err)
+	}
+
+	area := pawnRewardsToUserArea(rewards)
+	areaData, err := WriteUserArea(area)
+	if err != nil {
+		return nil, NewRequestError(request, userAreaSizeErrorID, err)
 	}
+
+	return areaData, nil
 }
 
-func disconnect(client *ClientConn) error {
-	err := client.Send(&DisconnectionNotification{})
+func validateUserAreaWrite(client *ClientConn, request *TusUserAreaWriteRequestHeader) error {
+	_, err := strconv.ParseUint(request.User, 16, 64)
+	if err != nil {
+		return NewRequestError(request, invalidUserErrorID, err)
+	}
+
+	return nil
+}
+
+func (s *Server) storeUserArea(client *ClientConn, request *TusUserAreaWriteRequestHeader, areaData []byte) error {
+	userID, err := strconv.ParseUint(request.User, 16, 64)
+	if err != nil {
+		return NewRequestError(request, invalidUserErrorID, err)
+	}
+
+	area, err := ReadUserArea(areaData)
+	if err != nil {
+		return NewRequestError(request, invalidUserAreaErrorID, err)
+	}
+
+	rewards := userAreaToPawnRewards(userID, area)
+	err = s.database.PutPawnRewards(rewards)
+	if err != nil {
+		return NewRequestError(request, storageErrorID, err)
+	}
+
+	return nil
+}
+
+// disconnect tells the client to disconnect and shows it the message, which may be empty.
+func disconnect(client *ClientConn, message string) error {
+	if deadliner, ok := client.ReadWriteCloser.(writeDeadliner); ok {
+		err := deadliner.SetWriteDeadline(time.Now().Add(disconnectWriteTimeout))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := client.Send(&DisconnectionNotification{Notification: message})
 	if err != nil {
 		return err
 	}
@@ -409,6 +642,7 @@ type serverListener struct {
 	connections      map[int64]net.Conn
 	connId           int64
 	close            chan bool
+	log              *logging.Logger
 }
 
 func (l *serverListener) Accept() (net.Conn, error) {
@@ -447,11 +681,11 @@ func (l *serverListener) CloseConns() {
 	l.connectionsMutex.Lock()
 	defer l.connectionsMutex.Unlock()
 
+	// Authenticated clients have already been notified by Shutdown, the rest are still in their handshake.
 	for connID, conn := range l.connections {
-		// TODO: Send a DisconnectionNotification Packet to the client before closing the connection.
 		err := conn.Close()
 		if err != nil {
-			printf("[%d] failed to forcefully close connection: %v", connID, err)
+			l.log.WithField("conn", connID).Warnf("failed to forcefully close connection: %v", err)
 		}
 
 		delete(l.connections, connID)
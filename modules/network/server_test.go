@@ -0,0 +1,335 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
+)
+
+type tokenRecorder struct {
+	token []byte
+	err   error
+}
+
+func (v *tokenRecorder) VerifyToken(user string, token []byte) (*Identity, error) {
+	v.token = token
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	return &Identity{User: user}, nil
+}
+
+func authenticatePipe(t *testing.T, cfg ServerConfig, clientCfg ClientConfig) (*ClientConn, error, error) {
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	s := newServer(cfg, nil)
+	c := &Client{cfg: clientCfg, conn: NewClientConn(clientSide, 0, false)}
+
+	clientErrs := make(chan error, 1)
+	go func() {
+		clientErrs <- c.authenticate()
+	}()
+
+	conn, serverErr := s.authenticate(serverSide, 1)
+	if serverErr != nil {
+		serverSide.Close()
+	}
+
+	return conn, serverErr, <-clientErrs
+}
+
+func TestAuthenticateChunked(t *testing.T) {
+	token := make([]byte, 1000)
+	for i := 0; i < len(token); i++ {
+		token[i] = byte(i)
+	}
+
+	verifier := &tokenRecorder{}
+	conn, serverErr, clientErr := authenticatePipe(t,
+		ServerConfig{TokenVerifier: verifier, AuthChunkLength: 64},
+		ClientConfig{User: "0110000100000001", UserToken: token})
+
+	if serverErr != nil {
+		t.Errorf("server failed to authenticate: %v", serverErr)
+		return
+	}
+
+	if clientErr != nil {
+		t.Errorf("client failed to authenticate: %v", clientErr)
+		return
+	}
+
+	if conn.User != "0110000100000001" {
+		t.Errorf("User mismatch %s %s", conn.User, "0110000100000001")
+	}
+
+	if !bytes.Equal(verifier.token, token) {
+		t.Error("token mismatch")
+	}
+}
+
+func TestAuthenticateRejected(t *testing.T) {
+	verifier := &tokenRecorder{err: errors.New("rejected")}
+	_, serverErr, clientErr := authenticatePipe(t,
+		ServerConfig{TokenVerifier: verifier},
+		ClientConfig{User: "0110000100000001", UserToken: []byte{1, 2, 3}})
+
+	if serverErr == nil {
+		t.Error("server accepted a rejected token")
+	}
+
+	if clientErr == nil {
+		t.Error("client accepted a failing authentication footer")
+	}
+}
+
+func TestAuthenticateOversizedToken(t *testing.T) {
+	verifier := &tokenRecorder{}
+	_, serverErr, clientErr := authenticatePipe(t,
+		ServerConfig{TokenVerifier: verifier},
+		ClientConfig{User: "0110000100000001", UserToken: make([]byte, maxAuthenticationDataLength+1)})
+
+	if serverErr == nil {
+		t.Error("server accepted an oversized token")
+	}
+
+	if clientErr == nil {
+		t.Error("client authenticated with an oversized token")
+	}
+}
+
+type memoryDatabase struct {
+	mutex   sync.Mutex
+	dragon  game.OnlineUrDragon
+	rewards map[uint64]*game.PawnRewards
+}
+
+func newMemoryDatabase() *memoryDatabase {
+	return &memoryDatabase{
+		dragon:  *(&game.OnlineUrDragon{}).NextGeneration(),
+		rewards: make(map[uint64]*game.PawnRewards),
+	}
+}
+
+func (db *memoryDatabase) GetOnlineUrDragon() (*game.OnlineUrDragon, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	d := db.dragon
+	return &d, nil
+}
+
+func (db *memoryDatabase) PutOnlineUrDragon(dragon *game.OnlineUrDragon) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.dragon = *dragon
+	return nil
+}
+
+func (db *memoryDatabase) UpdateOnlineUrDragon(update func(*game.OnlineUrDragon) error) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	d := db.dragon
+	err := update(&d)
+	if err != nil {
+		return err
+	}
+	db.dragon = d
+	return nil
+}
+
+func (db *memoryDatabase) GetPawnRewards(userID uint64) (*game.PawnRewards, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.rewards[userID], nil
+}
+
+func (db *memoryDatabase) PutPawnRewards(rewards *game.PawnRewards) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.rewards[rewards.PawnUserID] = rewards
+	return nil
+}
+
+func servePipe(s *Server) (*ClientConn, func()) {
+	serverSide, clientSide := net.Pipe()
+	client := NewClientConn(serverSide, 1, true)
+	client.EnableKeepAlive(0, 0)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.handleClient(client)
+		serverSide.Close()
+	}()
+
+	return NewClientConn(clientSide, 0, false), func() {
+		clientSide.Close()
+		<-done
+	}
+}
+
+func TestErrorResponseKeepsSession(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&TusUserAreaReadRequestHeader{User: "not hex"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	response, err := conn.Recv()
+	protocolErr, ok := err.(*ProtocolError)
+	if !ok {
+		t.Errorf("unexpected response %T %v", response, err)
+		return
+	}
+
+	if protocolErr.NameID != tusUserAreaReadHeaderID || protocolErr.ErrorID != invalidUserErrorID {
+		t.Errorf("unexpected error response %v", protocolErr)
+	}
+
+	err = conn.Send(&DisconnectionRequest{BooleanPacket{Value: true}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	response, err = conn.Recv()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, ok := response.(*DisconnectionResponse); !ok {
+		t.Errorf("unexpected response %T", response)
+	}
+}
+
+func TestInvalidChunkEndsSession(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&TusUserAreaWriteRequestHeader{User: "0110000100000001", DataLength: 16})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	response, err := conn.Recv()
+	if _, ok := response.(*TusUserAreaWriteResponseHeader); !ok || err != nil {
+		t.Errorf("unexpected response %T %v", response, err)
+		return
+	}
+
+	err = conn.Send(&TusUserAreaWriteRequestData{DataChunkPacket{ChunkOffset: 100, ChunkData: []byte{1, 2}}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = conn.Recv()
+	protocolErr, ok := err.(*ProtocolError)
+	if !ok || protocolErr.ErrorID != userAreaSizeErrorID {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	err = conn.Send(&TusUserAreaWriteRequestFooter{})
+	if err == nil {
+		response, err = conn.Recv()
+	}
+	if err == nil {
+		t.Errorf("session continued with %T after an invalid chunk", response)
+	}
+}
+
+func TestUnhandledRequestEndsSession(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	delete(s.handlers, disconnectionID)
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&DisconnectionRequest{BooleanPacket{Value: true}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	response, err := conn.Recv()
+	if _, ok := response.(*DisconnectionNotification); !ok || err != nil {
+		t.Errorf("unexpected response %T %v", response, err)
+		return
+	}
+
+	response, err = conn.Recv()
+	if err == nil {
+		t.Errorf("session continued with %T after an unhandled request", response)
+	}
+}
+
+func TestUnknownPacketKeepsSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unknown")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	s.unknownPackets, err = NewUnknownPacketArchive(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	unknownPacket := &UnknownPacket{Data: []byte{0xAB, 0xCD}}
+	unknownPacket.PacketType = PacketType{0x1301, requestID, noErrorID}
+	err = conn.Send(unknownPacket)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = conn.Recv()
+	protocolErr, ok := err.(*ProtocolError)
+	if !ok || protocolErr.NameID != 0x1301 || protocolErr.ErrorID != unknownPacketErrorID {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Errorf("unknown packet was not archived: %v", err)
+		return
+	}
+
+	archived, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil || !bytes.Contains(archived, []byte(`"payload": "abcd"`)) {
+		t.Errorf("unexpected archive %s %v", archived, err)
+	}
+
+	err = conn.Send(&TusCommonAreaAcquisitionRequest{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = conn.Recv()
+	if err != nil {
+		t.Errorf("session did not survive the unknown packet: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package network
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+const defaultShutdownMessage = "The server is shutting down."
+
+// Shutdown stops accepting connections and waits until every request that is being handled has finished,
+// including user area transactions and dragon updates. Then every client receives a disconnection notification
+// with the ShutdownMessage and is closed. If the context ends first the remaining clients are notified and closed
+// right away and the context error is returned. Only the first call shuts the server down.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.requestsMutex.Lock()
+	started := atomic.CompareAndSwapInt32(&s.shuttingDown, 0, 1)
+	s.requestsMutex.Unlock()
+
+	if !started {
+		return nil
+	}
+
+	s.listenerMutex.Lock()
+	l := s.listener
+	s.listenerMutex.Unlock()
+
+	if l != nil {
+		err := l.Close()
+		if err != nil {
+			s.config.Logger.Warnf("failed to close the listener: %v", err)
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.requests.Wait()
+		close(drained)
+	}()
+
+	var ctxErr error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		ctxErr = ctx.Err()
+		s.config.Logger.Warnf("shutdown deadline exceeded, closing clients with requests in flight: %v", ctxErr)
+	}
+
+	for _, client := range s.connectedClients() {
+		err := disconnectAndClose(client, s.config.ShutdownMessage)
+		if err != nil {
+			client.Logger().Debugf("failed to send the shutdown notification: %v", err)
+		}
+	}
+
+	if l != nil {
+		l.CloseConns()
+	}
+
+	if s.capture != nil {
+		err := s.capture.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return ctxErr
+}
+
+func (s *Server) isShuttingDown() bool {
+	return atomic.LoadInt32(&s.shuttingDown) != 0
+}
+
+// beginRequest registers a request that Shutdown waits for. It fails once the server is shutting down.
+func (s *Server) beginRequest() bool {
+	s.requestsMutex.RLock()
+	defer s.requestsMutex.RUnlock()
+
+	if s.isShuttingDown() {
+		return false
+	}
+
+	s.requests.Add(1)
+	return true
+}
+
+func (s *Server) endRequest() {
+	s.requests.Done()
+}
+
+// disconnectAndClose notifies and closes the client once, no matter how many goroutines try to.
+func disconnectAndClose(client *ClientConn, message string) error {
+	if !atomic.CompareAndSwapInt32(&client.closed, 0, 1) {
+		return nil
+	}
+
+	err := disconnect(client, message)
+	closeErr := client.ReadWriteCloser.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
@@ -0,0 +1,124 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+)
+
+func blockingServer(started chan<- bool, release <-chan bool) *Server {
+	s := newServer(ServerConfig{ShutdownMessage: "maintenance"}, newMemoryDatabase())
+	s.HandleFunc(tusCommonAreaAcquisitionID, func(client *ClientConn, request Packet) error {
+		started <- true
+		<-release
+		return client.Send(&TusCommonAreaAcquisitionResponse{})
+	})
+
+	return s
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	started, release := make(chan bool, 1), make(chan bool)
+	s := blockingServer(started, release)
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+
+	err := conn.Send(&TusCommonAreaAcquisitionRequest{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	<-started
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Shutdown(context.Background())
+	}()
+
+	select {
+	case err = <-done:
+		t.Errorf("shutdown did not wait for the request: %v", err)
+		return
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	response, err := conn.Recv()
+	if _, ok := response.(*TusCommonAreaAcquisitionResponse); !ok {
+		t.Errorf("unexpected response %v %v", response, err)
+	}
+
+	response, err = conn.Recv()
+	notification, ok := response.(*DisconnectionNotification)
+	if !ok || notification.Notification != "maintenance" {
+		t.Errorf("unexpected notification %v %v", response, err)
+	}
+
+	err = <-done
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	started, release := make(chan bool, 1), make(chan bool)
+	s := blockingServer(started, release)
+	conn, closePipe := servePipe(s)
+	defer closePipe()
+	defer close(release)
+
+	err := conn.Send(&TusCommonAreaAcquisitionRequest{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	<-started
+
+	notifications := make(chan Packet, 1)
+	go func() {
+		response, _ := conn.Recv()
+		notifications <- response
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = s.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if _, ok := (<-notifications).(*DisconnectionNotification); !ok {
+		t.Error("client was not notified")
+	}
+}
+
+func TestShutdownBeforeListen(t *testing.T) {
+	s := newServer(ServerConfig{}, newMemoryDatabase())
+	s.config.tlsConfig = &tls.Config{GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return nil, errors.New("no certificate")
+	}}
+
+	err := s.Shutdown(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ListenAndServe()
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("listener stayed open after shutdown")
+	}
+}
@@ -0,0 +1,135 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UserAreaReadTransaction serves the TusUserAreaRead header, data and footer exchange.
+// Load returns the encoded user area of the requested user.
+// Errors after the response header end the session because the client is already streaming data packets.
+type UserAreaReadTransaction struct {
+	Load func(client *ClientConn, request *TusUserAreaReadRequestHeader) ([]byte, error)
+}
+
+func (t *UserAreaReadTransaction) ServePacket(client *ClientConn, request Packet) error {
+	header, ok := request.(*TusUserAreaReadRequestHeader)
+	if !ok {
+		return NewRequestError(request, invalidRequestErrorID, NewPacketTypeError(header, request))
+	}
+
+	areaData, err := t.Load(client, header)
+	if err != nil {
+		return asRequestError(header, storageErrorID, err)
+	}
+
+	err = client.Send(&TusUserAreaReadResponseHeader{DataLength: uint32(len(areaData))})
+	if err != nil {
+		return err
+	}
+
+	for {
+		response, err := client.Recv()
+		if err != nil {
+			return err
+		}
+
+		switch response := response.(type) {
+		case *TusUserAreaReadRequestFooter:
+			return client.Send(&TusUserAreaReadResponseFooter{})
+		case *TusUserAreaReadRequestData:
+			chunkOffset := int(response.ChunkOffset)
+			chunkLength := int(response.ChunkLength)
+
+			if chunkLength > maxChunkLength || chunkOffset+chunkLength > len(areaData) {
+				return NewFatalRequestError(response, userAreaSizeErrorID, errors.New("read user area failed: invalid chunk"))
+			}
+
+			chunkData := areaData[chunkOffset : chunkOffset+chunkLength]
+			err = client.Send(&TusUserAreaReadResponseData{DataChunkPacket{ChunkOffset: uint32(chunkOffset), ChunkData: chunkData}})
+			if err != nil {
+				return err
+			}
+		default:
+			return NewFatalRequestError(response, invalidRequestErrorID, fmt.Errorf("read user area failed: unexpected response %T", response))
+		}
+	}
+}
+
+// UserAreaWriteTransaction serves the TusUserAreaWrite header, data and footer exchange.
+// The optional Validate rejects the header before any data is accepted.
+// Store receives the complete encoded user area once the footer arrives.
+// Invalid chunks end the session because the client is already streaming data packets.
+type UserAreaWriteTransaction struct {
+	ChunkLength uint16
+	Validate    func(client *ClientConn, request *TusUserAreaWriteRequestHeader) error
+	Store       func(client *ClientConn, request *TusUserAreaWriteRequestHeader, data []byte) error
+}
+
+func (t *UserAreaWriteTransaction) ServePacket(client *ClientConn, request Packet) error {
+	header, ok := request.(*TusUserAreaWriteRequestHeader)
+	if !ok {
+		return NewRequestError(request, invalidRequestErrorID, NewPacketTypeError(header, request))
+	}
+
+	if t.Validate != nil {
+		err := t.Validate(client, header)
+		if err != nil {
+			return asRequestError(header, invalidRequestErrorID, err)
+		}
+	}
+
+	if header.DataLength > maxDataLength {
+		return NewRequestError(header, userAreaSizeErrorID, errors.New("write user area failed: invalid size"))
+	}
+
+	areaData := make([]byte, int(header.DataLength))
+
+	err := client.Send(&TusUserAreaWriteResponseHeader{ChunkLength: t.ChunkLength})
+	if err != nil {
+		return err
+	}
+
+	for {
+		response, err := client.Recv()
+		if err != nil {
+			return err
+		}
+
+		switch response := response.(type) {
+		case *TusUserAreaWriteRequestFooter:
+			err = t.Store(client, header, areaData)
+			if err != nil {
+				return asRequestError(response, storageErrorID, err)
+			}
+
+			return client.Send(&TusUserAreaWriteResponseFooter{})
+		case *TusUserAreaWriteRequestData:
+			chunkOffset := int(response.ChunkOffset)
+			chunkData := response.ChunkData
+			chunkLength := len(chunkData)
+
+			if chunkLength > maxChunkLength || chunkOffset+chunkLength > len(areaData) {
+				return NewFatalRequestError(response, userAreaSizeErrorID, errors.New("write user area failed: invalid chunk"))
+			}
+
+			copy(areaData[chunkOffset:chunkOffset+chunkLength], chunkData)
+
+			err = client.Send(&TusUserAreaWriteResponseData{DataChunkReferencePacket{ChunkOffset: uint32(chunkOffset), ChunkLength: uint16(chunkLength)}})
+			if err != nil {
+				return err
+			}
+		default:
+			return NewFatalRequestError(response, invalidRequestErrorID, fmt.Errorf("write user area failed: unexpected response %T", response))
+		}
+	}
+}
+
+func asRequestError(request Packet, errorID PacketErrorID, err error) error {
+	if reqErr, ok := err.(*RequestError); ok {
+		reqErr.PacketType = GetPacketType(request)
+		return reqErr
+	}
+
+	return NewRequestError(request, errorID, err)
+}
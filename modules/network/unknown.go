@@ -0,0 +1,127 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// UnknownPacket keeps the header and the raw payload of a packet that is not registered.
+type UnknownPacket struct {
+	PacketHeader
+	Data []byte
+}
+
+func (p *UnknownPacket) Payload() ([]byte, error) {
+	return p.Data, nil
+}
+
+func (p *UnknownPacket) SetPayload(payload []byte) error {
+	p.Data = make([]byte, len(payload))
+	copy(p.Data, payload)
+	return nil
+}
+
+type archivedPacket struct {
+	Time       time.Time `json:"time"`
+	ConnID     int64     `json:"connectionId"`
+	User       string    `json:"user,omitempty"`
+	RemoteAddr string    `json:"remoteAddress,omitempty"`
+	SequenceID uint16    `json:"sequenceId"`
+	NameID     string    `json:"nameId"`
+	TypeID     string    `json:"typeId"`
+	ErrorID    string    `json:"errorId"`
+	Length     uint16    `json:"length"`
+	Payload    string    `json:"payload"`
+}
+
+// UnknownPacketArchive stores every unknown packet as a JSON file together with the connection it arrived on.
+// It stops at maxArchivedUnknownPackets files or maxArchivedUnknownBytes so that clients cannot fill the disk.
+type UnknownPacketArchive struct {
+	dir      string
+	maxFiles int
+	maxBytes int64
+	mutex    sync.Mutex
+	files    int
+	bytes    int64
+}
+
+var ErrUnknownPacketArchiveFull = errors.New("unknown packet archive is full")
+
+const (
+	unknownPacketDirMode      = 0755
+	unknownPacketFileMode     = 0644
+	maxArchivedUnknownPackets = 10000
+	maxArchivedUnknownBytes   = 64 << 20
+)
+
+func NewUnknownPacketArchive(dir string) (*UnknownPacketArchive, error) {
+	err := os.MkdirAll(dir, unknownPacketDirMode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnknownPacketArchive{
+		dir:      dir,
+		maxFiles: maxArchivedUnknownPackets,
+		maxBytes: maxArchivedUnknownBytes,
+	}, nil
+}
+
+// Archive returns the path of the file the packet was written to.
+// It returns ErrUnknownPacketArchiveFull once the archive has reached its limits.
+func (a *UnknownPacketArchive) Archive(client *ClientConn, packet *UnknownPacket) (string, error) {
+	now := time.Now().UTC()
+	packetType := packet.PacketType
+
+	archived := archivedPacket{
+		Time:       now,
+		ConnID:     client.ID,
+		User:       client.User,
+		RemoteAddr: client.remoteAddr(),
+		SequenceID: packet.SequenceID,
+		NameID:     fmt.Sprintf("0x%04x", uint16(packetType.NameID)),
+		TypeID:     fmt.Sprintf("0x%02x", uint8(packetType.TypeID)),
+		ErrorID:    fmt.Sprintf("0x%02x", uint8(packetType.ErrorID)),
+		Length:     packet.Length,
+		Payload:    hex.EncodeToString(packet.Data),
+	}
+
+	data, err := json.MarshalIndent(archived, "", "    ")
+	if err != nil {
+		return "", err
+	}
+
+	size := int64(len(data))
+	a.mutex.Lock()
+	if a.files >= a.maxFiles || a.bytes+size > a.maxBytes {
+		a.mutex.Unlock()
+		return "", ErrUnknownPacketArchiveFull
+	}
+	a.files++
+	a.bytes += size
+	index := a.files
+	a.mutex.Unlock()
+
+	name := fmt.Sprintf("%s_%d_%04x_%02x_%d.json",
+		now.Format("20060102T150405.000000000"), client.ID, uint16(packetType.NameID), uint8(packetType.TypeID), index)
+	path := filepath.Join(a.dir, name)
+
+	err = ioutil.WriteFile(path, data, unknownPacketFileMode)
+	if err != nil {
+		// Give the space back, a failed write does not count against the limits.
+		a.mutex.Lock()
+		a.files--
+		a.bytes -= size
+		a.mutex.Unlock()
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,92 @@
+package network
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestUnknownPacketArchiveLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unknown")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := NewUnknownPacketArchive(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	archive.maxFiles = 3
+
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+	client := NewClientConn(serverSide, 1, true)
+
+	packet := &UnknownPacket{Data: []byte{0xAB}}
+	packet.PacketType = PacketType{0x1301, requestID, noErrorID}
+	for i := 0; i < archive.maxFiles; i++ {
+		_, err = archive.Archive(client, packet)
+		if err != nil {
+			t.Errorf("packet %d was not archived: %v", i, err)
+			return
+		}
+	}
+
+	_, err = archive.Archive(client, packet)
+	if err != ErrUnknownPacketArchiveFull {
+		t.Errorf("archive was not capped by files: %v", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != archive.maxFiles {
+		t.Errorf("archived file count mismatch %d %d %v", len(files), archive.maxFiles, err)
+	}
+
+	archive.maxFiles = 10
+	archive.maxBytes = archive.bytes
+	_, err = archive.Archive(client, packet)
+	if err != ErrUnknownPacketArchiveFull {
+		t.Errorf("archive was not capped by bytes: %v", err)
+	}
+}
+
+func TestUnknownPacketArchiveWriteFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unknown")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := NewUnknownPacketArchive(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	archive.maxFiles = 1
+
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+	client := NewClientConn(serverSide, 1, true)
+
+	packet := &UnknownPacket{Data: []byte{0xAB}}
+	packet.PacketType = PacketType{0x1301, requestID, noErrorID}
+
+	archive.dir = dir + "/missing"
+	_, err = archive.Archive(client, packet)
+	if err == nil || err == ErrUnknownPacketArchiveFull {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	archive.dir = dir
+	_, err = archive.Archive(client, packet)
+	if err != nil {
+		t.Errorf("failed write was counted against the limits: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/atvaark/dragons-dogma-server/modules/auth"
+)
+
+type Identity struct {
+	User    string
+	SteamID uint64
+}
+
+type TokenVerifier interface {
+	VerifyToken(user string, token []byte) (*Identity, error)
+}
+
+type AllowAllTokenVerifier struct{}
+
+func (AllowAllTokenVerifier) VerifyToken(user string, token []byte) (*Identity, error) {
+	steamID, _ := strconv.ParseUint(user, 16, 64)
+	return &Identity{User: user, SteamID: steamID}, nil
+}
+
+type DatabaseTokenVerifier struct {
+	database auth.TokenDatabase
+}
+
+func NewDatabaseTokenVerifier(database auth.TokenDatabase) *DatabaseTokenVerifier {
+	return &DatabaseTokenVerifier{database: database}
+}
+
+func (v *DatabaseTokenVerifier) VerifyToken(user string, token []byte) (*Identity, error) {
+	expectedToken, err := v.database.GetGameToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedToken == nil {
+		return nil, fmt.Errorf("no token registered for user '%s'", user)
+	}
+
+	if subtle.ConstantTimeCompare(expectedToken, token) != 1 {
+		return nil, errors.New("token mismatch")
+	}
+
+	steamID, _ := strconv.ParseUint(user, 16, 64)
+	return &Identity{User: user, SteamID: steamID}, nil
+}
+
+type SteamTokenVerifier struct {
+	apiURL   string
+	steamKey string
+}
+
+func NewSteamTokenVerifier(apiURL string, steamKey string) *SteamTokenVerifier {
+	if len(apiURL) == 0 {
+		apiURL = auth.SteamAPIURL
+	}
+
+	return &SteamTokenVerifier{
+		apiURL:   apiURL,
+		steamKey: steamKey,
+	}
+}
+
+func (v *SteamTokenVerifier) VerifyToken(user string, token []byte) (*Identity, error) {
+	userSteamID, err := strconv.ParseUint(user, 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user '%s'", user)
+	}
+
+	steamID, err := auth.AuthenticateUserTicket(v.apiURL, v.steamKey, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if steamID != userSteamID {
+		return nil, fmt.Errorf("ticket owner %d does not match user '%s'", steamID, user)
+	}
+
+	return &Identity{User: user, SteamID: steamID}, nil
+}
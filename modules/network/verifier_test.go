@@ -0,0 +1,95 @@
+package network
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type memoryTokenDatabase map[string][]byte
+
+func (db memoryTokenDatabase) GetGameToken(user string) ([]byte, error) {
+	return db[user], nil
+}
+
+func (db memoryTokenDatabase) PutGameToken(user string, token []byte) error {
+	db[user] = token
+	return nil
+}
+
+func TestAllowAllTokenVerifier(t *testing.T) {
+	identity, err := AllowAllTokenVerifier{}.VerifyToken("0110000100000001", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if identity.User != "0110000100000001" {
+		t.Errorf("User mismatch %s %s", identity.User, "0110000100000001")
+	}
+}
+
+func TestDatabaseTokenVerifier(t *testing.T) {
+	database := memoryTokenDatabase{"0110000100000001": []byte("secret")}
+	verifier := NewDatabaseTokenVerifier(database)
+
+	_, err := verifier.VerifyToken("0110000100000001", []byte("secret"))
+	if err != nil {
+		t.Errorf("valid token was rejected: %v", err)
+	}
+
+	_, err = verifier.VerifyToken("0110000100000001", []byte("wrong"))
+	if err == nil {
+		t.Error("invalid token was accepted")
+	}
+
+	_, err = verifier.VerifyToken("0110000100000002", []byte("secret"))
+	if err == nil {
+		t.Error("unknown user was accepted")
+	}
+}
+
+func TestSteamTokenVerifier(t *testing.T) {
+	const steamID = uint64(76561197960287930)
+	ticket := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+
+	steam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ISteamUserAuth/AuthenticateUserTicket/v1/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("ticket") != hex.EncodeToString(ticket) {
+			fmt.Fprint(w, `{"response":{"error":{"errorcode":101,"errordesc":"Invalid ticket"}}}`)
+			return
+		}
+
+		fmt.Fprintf(w, `{"response":{"params":{"result":"OK","steamid":"%d","ownersteamid":"%d","vacbanned":false,"publisherbanned":false}}}`, steamID, steamID)
+	}))
+	defer steam.Close()
+
+	verifier := NewSteamTokenVerifier(steam.URL, "key")
+	user := fmt.Sprintf("%016x", steamID)
+
+	identity, err := verifier.VerifyToken(user, ticket)
+	if err != nil {
+		t.Errorf("valid ticket was rejected: %v", err)
+		return
+	}
+
+	if identity.SteamID != steamID {
+		t.Errorf("SteamID mismatch %d %d", identity.SteamID, steamID)
+	}
+
+	_, err = verifier.VerifyToken(user, []byte{0x00})
+	if err == nil {
+		t.Error("invalid ticket was accepted")
+	}
+
+	_, err = verifier.VerifyToken(fmt.Sprintf("%016x", steamID+1), ticket)
+	if err == nil {
+		t.Error("ticket of another user was accepted")
+	}
+}
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
+)
+
+const defaultTickInterval = 1 * time.Minute
+
+type SchedulerConfig struct {
+	TickInterval time.Duration
+	OnKill       func(dragon *game.OnlineUrDragon)
+	OnGeneration func(previous, next *game.OnlineUrDragon)
+	Logger       *logging.Logger
+}
+
+type Scheduler struct {
+	cfg       SchedulerConfig
+	database  game.DragonDatabase
+	log       *logging.Logger
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+func NewScheduler(cfg SchedulerConfig, database game.DragonDatabase) *Scheduler {
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = defaultTickInterval
+	}
+
+	return &Scheduler{
+		cfg:      cfg,
+		database: database,
+		log:      cfg.Logger.WithField("component", "scheduler"),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Start() {
+	s.startOnce.Do(func() {
+		s.log.Infof("Ticking the dragon every %v", s.cfg.TickInterval)
+		go s.run()
+	})
+}
+
+func (s *Scheduler) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+
+	started := true
+	s.startOnce.Do(func() {
+		started = false
+	})
+
+	if started {
+		<-s.done
+	}
+
+	return nil
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			err := s.Tick()
+			if err != nil {
+				s.log.Errorf("tick failed: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) Tick() error {
+	// Most ticks change nothing, so check a copy before taking the write transaction.
+	current, err := s.database.GetOnlineUrDragon()
+	if err != nil {
+		return err
+	}
+
+	if !tickChanges(current) {
+		return nil
+	}
+
+	var previous, next, killed *game.OnlineUrDragon
+
+	err = s.database.UpdateOnlineUrDragon(func(dragon *game.OnlineUrDragon) error {
+		current := *dragon
+		wasKilled := dragon.KillTime != nil
+
+		ticked := dragon.Tick()
+		if ticked != dragon {
+			previous, next = &current, ticked
+			*dragon = *ticked
+		} else if !wasKilled && dragon.KillTime != nil {
+			killed = &current
+			killed.KillTime = dragon.KillTime
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case next != nil:
+		s.log.Infof("Ur Dragon advanced from generation %d to %d", previous.Generation, next.Generation)
+		if s.cfg.OnGeneration != nil {
+			s.cfg.OnGeneration(previous, next)
+		}
+	case killed != nil:
+		s.log.Infof("Ur Dragon generation %d killed at %v", killed.Generation, killed.KillTime)
+		if s.cfg.OnKill != nil {
+			s.cfg.OnKill(killed)
+		}
+	}
+
+	return nil
+}
+
+// tickChanges ticks the dragon and reports whether it was killed or advanced to the next generation.
+func tickChanges(dragon *game.OnlineUrDragon) bool {
+	wasKilled := dragon.KillTime != nil
+	return dragon.Tick() != dragon || (!wasKilled && dragon.KillTime != nil)
+}
@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atvaark/dragons-dogma-server/modules/game"
+)
+
+type memoryDragonDatabase struct {
+	mutex   sync.Mutex
+	dragon  *game.OnlineUrDragon
+	updates int
+}
+
+func (db *memoryDragonDatabase) GetOnlineUrDragon() (*game.OnlineUrDragon, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.dragon == nil {
+		return nil, errors.New("dragon not found")
+	}
+
+	d := *db.dragon
+	return &d, nil
+}
+
+func (db *memoryDragonDatabase) PutOnlineUrDragon(dragon *game.OnlineUrDragon) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	d := *dragon
+	db.dragon = &d
+	return nil
+}
+
+func (db *memoryDragonDatabase) UpdateOnlineUrDragon(update func(*game.OnlineUrDragon) error) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.dragon == nil {
+		return errors.New("dragon not found")
+	}
+
+	db.updates++
+	d := *db.dragon
+	err := update(&d)
+	if err != nil {
+		return err
+	}
+
+	db.dragon = &d
+	return nil
+}
+
+func TestTickAlive(t *testing.T) {
+	dragon := (&game.OnlineUrDragon{}).NextGeneration()
+	database := &memoryDragonDatabase{dragon: dragon}
+
+	var called bool
+	s := NewScheduler(SchedulerConfig{
+		OnKill:       func(*game.OnlineUrDragon) { called = true },
+		OnGeneration: func(_, _ *game.OnlineUrDragon) { called = true },
+	}, database)
+
+	err := s.Tick()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if called {
+		t.Error("unexpected lifecycle event for a living dragon")
+	}
+
+	if database.dragon.Generation != dragon.Generation || database.dragon.KillTime != nil {
+		t.Error("living dragon was modified")
+	}
+
+	if database.updates != 0 {
+		t.Errorf("unchanged dragon was written %d times", database.updates)
+	}
+}
+
+func TestTickKill(t *testing.T) {
+	dragon := (&game.OnlineUrDragon{}).NextGeneration()
+	for i := 0; i < len(dragon.Hearts); i++ {
+		dragon.Hearts[i].Health = 0
+	}
+	database := &memoryDragonDatabase{dragon: dragon}
+
+	var killed *game.OnlineUrDragon
+	s := NewScheduler(SchedulerConfig{OnKill: func(d *game.OnlineUrDragon) { killed = d }}, database)
+
+	err := s.Tick()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if database.dragon.KillTime == nil {
+		t.Error("KillTime was not persisted")
+	}
+
+	if killed == nil {
+		t.Error("OnKill was not called")
+	}
+}
+
+func TestTickNextGeneration(t *testing.T) {
+	dragon := (&game.OnlineUrDragon{}).NextGeneration()
+	killTime := time.Now().UTC().Add(-game.GraceTime - time.Minute)
+	dragon.KillTime = &killTime
+	dragon.KillCount = game.GraceKillsMin
+	database := &memoryDragonDatabase{dragon: dragon}
+
+	var previous, next *game.OnlineUrDragon
+	s := NewScheduler(SchedulerConfig{OnGeneration: func(p, n *game.OnlineUrDragon) { previous, next = p, n }}, database)
+
+	err := s.Tick()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if database.dragon.Generation != dragon.Generation+1 {
+		t.Errorf("Generation mismatch %d %d", database.dragon.Generation, dragon.Generation+1)
+	}
+
+	if database.dragon.KillTime != nil {
+		t.Error("KillTime was not reset")
+	}
+
+	if previous == nil || next == nil || next.Generation != previous.Generation+1 {
+		t.Error("OnGeneration was not called")
+	}
+}
+
+func TestStartClose(t *testing.T) {
+	database := &memoryDragonDatabase{dragon: (&game.OnlineUrDragon{}).NextGeneration()}
+	s := NewScheduler(SchedulerConfig{TickInterval: time.Millisecond}, database)
+	s.Start()
+	time.Sleep(10 * time.Millisecond)
+
+	err := s.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	unstarted := NewScheduler(SchedulerConfig{}, database)
+	err = unstarted.Close()
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/atvaark/dragons-dogma-server/modules/auth"
+	"github.com/atvaark/dragons-dogma-server/modules/logging"
 )
 
 type Website struct {
@@ -21,6 +22,9 @@ type WebsiteConfig struct {
 	RootURL    string
 	Port       int
 	AuthConfig AuthConfig
+	// Maintenance reports whether the game server is in maintenance and the message it shows, the home page shows it as a banner.
+	Maintenance func() (bool, string)
+	Logger      *logging.Logger
 }
 
 var (
@@ -30,9 +34,10 @@ var (
 
 func NewWebsite(cfg WebsiteConfig, database auth.Database) *Website {
 	sessionHandler := auth.NewSessionHandler(database)
-	authHandler := auth.NewAuthHandler(cfg.RootURL, "/login/", cfg.AuthConfig.SteamKey)
-	homeHandler := &homeHandler{cfg.RootURL, "/", sessionHandler}
-	loginHandler := &loginHandler{cfg.RootURL, "/login/", sessionHandler, authHandler}
+	log := cfg.Logger.WithField("component", "website")
+	authHandler := auth.NewAuthHandler(cfg.RootURL, "/login/", cfg.AuthConfig.SteamKey, cfg.Logger)
+	homeHandler := &homeHandler{cfg.RootURL, "/", sessionHandler, cfg.Maintenance, log}
+	loginHandler := &loginHandler{cfg.RootURL, "/login/", sessionHandler, authHandler, log}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc(homeHandler.path, homeHandler.handle)
@@ -57,8 +62,8 @@ func (w *Website) ListenAndServe() error {
 	return nil
 }
 
-func (w *Website) Close() error {
-	var ctx context.Context
+// Shutdown stops accepting requests and waits for the running ones until the context ends.
+func (w *Website) Shutdown(ctx context.Context) error {
 	err := w.server.Shutdown(ctx)
 	if err != nil {
 		return err
@@ -67,6 +72,10 @@ func (w *Website) Close() error {
 	return nil
 }
 
+func (w *Website) Close() error {
+	return w.server.Close()
+}
+
 type rootModel struct {
 	RootURL string
 }
@@ -75,12 +84,16 @@ type homeHandler struct {
 	rootURL        string
 	path           string
 	sessionHandler *auth.SessionHandler
+	maintenance    func() (bool, string)
+	log            *logging.Logger
 }
 
 type homeModel struct {
 	rootModel
-	PersonaName string
-	LoggedIn    bool
+	PersonaName        string
+	LoggedIn           bool
+	Maintenance        bool
+	MaintenanceMessage string
 }
 
 func (h *homeHandler) handle(w http.ResponseWriter, r *http.Request) {
@@ -94,7 +107,14 @@ func (h *homeHandler) handle(w http.ResponseWriter, r *http.Request) {
 		model.LoggedIn = true
 	}
 
-	homeTemplate.Execute(w, model)
+	if h.maintenance != nil {
+		model.Maintenance, model.MaintenanceMessage = h.maintenance()
+	}
+
+	err := homeTemplate.Execute(w, model)
+	if err != nil {
+		h.log.Errorf("failed to render the home page: %v", err)
+	}
 }
 
 type loginHandler struct {
@@ -102,6 +122,7 @@ type loginHandler struct {
 	path           string
 	sessionHandler *auth.SessionHandler
 	authHandler    *auth.AuthHandler
+	log            *logging.Logger
 }
 
 type loginModel struct {
@@ -121,6 +142,8 @@ func (h *loginHandler) handle(w http.ResponseWriter, r *http.Request) {
 
 		if err == nil {
 			h.sessionHandler.SetSessionCookie(w, user)
+		} else {
+			h.log.WithField("remoteAddress", r.RemoteAddr).Warnf("login failed: %v", err)
 		}
 	}
 
@@ -135,5 +158,8 @@ func (h *loginHandler) handle(w http.ResponseWriter, r *http.Request) {
 		model.Error = err.Error()
 	}
 
-	loginTemplate.Execute(w, model)
+	err = loginTemplate.Execute(w, model)
+	if err != nil {
+		h.log.Errorf("failed to render the login page: %v", err)
+	}
 }